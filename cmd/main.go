@@ -1,16 +1,31 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/Weit145/Auth_golang/internal/config"
 	"github.com/Weit145/Auth_golang/internal/grpc/gateway"
+	httpserver "github.com/Weit145/Auth_golang/internal/http"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+	"github.com/Weit145/Auth_golang/internal/lib/outbox"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
 	"github.com/Weit145/Auth_golang/internal/service"
+	"github.com/Weit145/Auth_golang/internal/service/emailchange"
+	"github.com/Weit145/Auth_golang/internal/service/passwordreset"
+	"github.com/Weit145/Auth_golang/internal/service/registration"
 	"github.com/Weit145/Auth_golang/internal/storage/postgresql"
 )
 
@@ -19,19 +34,91 @@ func main() {
 	cfg := config.MustLoad()
 
 	//Init logger
-	log := setupLogger(cfg.Env)
+	log := setupLogger(cfg.Env, cfg.Log)
 	log.Info("Start AUTH")
 
 	//Init storage
-	db, err := postgresql.New(log)
+	connectCtx, cancelConnect := context.WithTimeout(context.Background(), cfg.Storage.ConnectTimeout)
+	db, err := postgresql.New(connectCtx, log, cfg.Storage)
+	cancelConnect()
 	if err != nil {
 		log.Error("No connect storage", logger.Err(err))
 		os.Exit(1)
 	}
 	log.Info("Connect db")
 
+	//Init password hasher
+	passwordHasher, err := hasher.New(cfg)
+	if err != nil {
+		log.Error("cannot create password hasher", logger.Err(err))
+		os.Exit(1)
+	}
+
+	policy, err := passwordpolicy.New(cfg)
+	if err != nil {
+		log.Error("cannot create password policy", logger.Err(err))
+		os.Exit(1)
+	}
+
+	rules, err := identity.New(cfg)
+	if err != nil {
+		log.Error("cannot create login and email rules", logger.Err(err))
+		os.Exit(1)
+	}
+
+	//Init jwt keys
+	keys, err := myjwt.LoadKeys(cfg)
+	if err != nil {
+		log.Error("cannot load jwt keys", logger.Err(err))
+		os.Exit(1)
+	}
+
+	//Init token revocation store
+	revoked, err := revocation.New(cfg, log, db)
+	if err != nil {
+		log.Error("cannot create revocation store", logger.Err(err))
+		os.Exit(1)
+	}
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	go revocation.RunPurger(purgeCtx, log, revoked, cfg.Revocation.PurgeInterval)
+
+	//Init rate limits
+	limits, err := ratelimit.New(cfg, db)
+	if err != nil {
+		log.Error("cannot create rate limit store", logger.Err(err))
+		os.Exit(1)
+	}
+	go ratelimit.RunPurger(purgeCtx, log, limits, cfg.RateLimit.PurgeInterval)
+	limiter := &ratelimit.Limiter{Store: limits, Cfg: cfg.RateLimit}
+
+	//Init mailer
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Error("cannot create mailer", logger.Err(err))
+		os.Exit(1)
+	}
+
 	// Init registration service
-	Service := service.New(log, db, cfg)
+	Service := service.New(log, db, passwordHasher, policy, rules, keys, revoked, limits, mail, cfg)
+
+	//Init outbox dispatcher
+	dispatcher := &outbox.Dispatcher{
+		Store: db,
+		Handlers: map[string]outbox.Handler{
+			registration.KindVerificationEmail:   Service.Registration.SendVerificationEmail,
+			passwordreset.KindPasswordResetEmail: Service.Reset.SendResetEmail,
+			emailchange.KindEmailChange:          Service.EmailChange.SendEmailChange,
+			outbox.KindWebhook:                   outbox.Webhook(http.DefaultClient),
+		},
+		Cfg: cfg.Outbox,
+		Log: log,
+	}
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
 
 	//Init grpc
 	lis, err := net.Listen("tcp", cfg.GRPC.Address)
@@ -40,12 +127,57 @@ func main() {
 		os.Exit(1)
 	}
 
-	grpcServer, err := gateway.New(log, Service, lis)
+	clients, err := clientinfo.NewResolver(cfg.GRPC.TrustedProxies)
+	if err != nil {
+		log.Error("cannot parse trusted proxies", logger.Err(err))
+		os.Exit(1)
+	}
+
+	grpcServer, err := gateway.New(log, Service, lis, limiter, keys, clients)
 	if err != nil {
 		log.Error("cannot create server", logger.Err(err))
 		os.Exit(1)
 	}
 
+	//Init http
+	httpLis, err := net.Listen("tcp", cfg.HTTP.Address)
+	if err != nil {
+		log.Error("failed to listen", logger.Err(err))
+		os.Exit(1)
+	}
+
+	httpServer, err := httpserver.New(log, Service, httpLis)
+	if err != nil {
+		log.Error("cannot create http server", logger.Err(err))
+		os.Exit(1)
+	}
+
+	var adminServer *http.Server
+	if cfg.HTTP.AdminAddress != "" {
+		adminLis, err := net.Listen("tcp", cfg.HTTP.AdminAddress)
+		if err != nil {
+			log.Error("failed to listen", logger.Err(err))
+			os.Exit(1)
+		}
+
+		adminServer, err = httpserver.NewAdmin(log, adminLis)
+		if err != nil {
+			log.Error("cannot create admin http server", logger.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	//Reload signing keys on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := Service.SigningKeys.Reload(context.Background()); err != nil {
+				log.Error("failed to rotate signing keys", logger.Err(err))
+			}
+		}
+	}()
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
@@ -53,19 +185,37 @@ func main() {
 	log.Info("Shutting down gRPC server...")
 	grpcServer.GracefulStop()
 
+	log.Info("Stopping outbox dispatcher...")
+	stopDispatch()
+	<-dispatchDone
+
+	log.Info("Shutting down HTTP server...")
+	if err := httpServer.Shutdown(context.Background()); err != nil {
+		log.Error("failed to shutdown http server", logger.Err(err))
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(context.Background()); err != nil {
+			log.Error("failed to shutdown admin http server", logger.Err(err))
+		}
+	}
+
+	stopPurge()
+
+	log.Info("Closing storage...")
+	db.Close()
 }
 
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
+func setupLogger(env string, cfg config.Log) *slog.Logger {
+	var handler slog.Handler
 
 	switch env {
 	case "local":
-		log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
 	case "prod":
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
 	default:
-		log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
 	}
 
-	return log
+	return slog.New(logger.NewRedactHandler(handler, cfg.RedactKeys))
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -9,27 +10,267 @@ import (
 )
 
 type Config struct {
-	Env      string `yaml:"env" env-default:"local"`
-	GRPC     Grpc   `yaml:"grpc"`
-	JWT      JWT
-	TokenTTL TokenTTL `yaml:"token_ttl"`
+	Env           string        `yaml:"env" env-default:"local"`
+	GRPC          Grpc          `yaml:"grpc"`
+	HTTP          HTTP          `yaml:"http"`
+	JWT           JWT           `yaml:"jwt"`
+	TokenTTL      TokenTTL      `yaml:"token_ttl"`
+	Password      Password      `yaml:"password"`
+	Revocation    Revocation    `yaml:"revocation"`
+	Introspection Introspection `yaml:"introspection"`
+	Mail          Mail          `yaml:"mail"`
+	Outbox        Outbox        `yaml:"outbox"`
+	EmailCooldown EmailCooldown `yaml:"email_cooldown"`
+	Validation    Validation    `yaml:"validation"`
+	Lockout       Lockout       `yaml:"lockout"`
+	RateLimit     RateLimit     `yaml:"rate_limit"`
+	Account       Account       `yaml:"account"`
+	Log           Log           `yaml:"log"`
+	Storage       Storage       `yaml:"storage"`
 }
 
 type Grpc struct {
 	Address string `yaml:"address" env-default:"auth-service:50051"`
+	// TrustedProxies are the CIDRs of proxies whose x-forwarded-for hops
+	// are believed. With none, the client IP is always the transport peer.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"GRPC_TRUSTED_PROXIES"`
+}
+
+type HTTP struct {
+	Address string `yaml:"address" env-default:"auth-service:8080"`
+	// AdminAddress serves /debug/vars apart from the public port; keep it
+	// on loopback or an internal network. Empty disables it.
+	AdminAddress string `yaml:"admin_address" env:"HTTP_ADMIN_ADDRESS" env-default:"127.0.0.1:9090"`
 }
 
 type JWT struct {
-	Secret    string `env:"SECRET_JWT" env-required:"true"`
-	Algorithm string `env:"ALGORITHM_JWT" env-required:"true"`
+	Secret         string `env:"SECRET_JWT"`
+	Algorithm      string `env:"ALGORITHM_JWT" env-required:"true"`
+	PrivateKeyPath string `yaml:"private_key_path" env:"PRIVATE_KEY_PATH_JWT"`
+	// RetiredKeys keep verifying tokens after rotation but never sign new ones.
+	RetiredKeys []RetiredKey `yaml:"retired_keys"`
+}
+
+type RetiredKey struct {
+	Algorithm      string `yaml:"algorithm"`
+	PrivateKeyPath string `yaml:"private_key_path"`
 }
 
 type TokenTTL struct {
-	Access  time.Duration `yaml:"access" env-default:"1h"`
-	Refresh time.Duration `yaml:"refresh" env-default:"72h"`
+	Access       time.Duration `yaml:"access" env-default:"1h"`
+	Refresh      time.Duration `yaml:"refresh" env-default:"72h"`
+	Verification time.Duration `yaml:"verification" env-default:"30m"`
+	// PasswordReset is the lifetime of the opaque reset tokens sent by email.
+	PasswordReset time.Duration `yaml:"password_reset" env-default:"30m"`
+	// EmailChange is how long a pending email address waits for confirmation.
+	EmailChange time.Duration `yaml:"email_change" env-default:"24h"`
+}
+
+// Revocation selects where revoked token ids are kept until they expire.
+type Revocation struct {
+	Backend string `yaml:"backend" env:"REVOCATION_BACKEND" env-default:"postgres"`
+	// Capacity bounds the memory backend. Once it is full of tokens that
+	// have not expired yet, revoking another one fails with
+	// revocation.ErrFull rather than forgetting a live one, so logging out
+	// fails until some of them expire. The postgres backend has no limit.
+	Capacity      int           `yaml:"capacity" env-default:"100000"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"10m"`
+}
+
+type Introspection struct {
+	// CacheTTL is how long an active answer for an access token is reused;
+	// zero disables the cache. Revoked token ids are checked on every call,
+	// but a session revoked or a password changed on another instance is
+	// seen here only once the cached answer expires.
+	CacheTTL time.Duration `yaml:"cache_ttl" env-default:"10s"`
+	// Clients may introspect tokens, as RFC 7662 §2.1 requires callers to
+	// authenticate. Each client id maps to the hex SHA-256 of its secret;
+	// clients send both as HTTP Basic credentials in the authorization
+	// metadata.
+	Clients map[string]string `yaml:"clients" env:"INTROSPECTION_CLIENTS"`
+}
+
+type Mail struct {
+	// Backend is one of "smtp", "file" or "memory".
+	Backend string `yaml:"backend" env:"MAIL_BACKEND" env-default:"file"`
+	From    string `yaml:"from" env:"MAIL_FROM" env-default:"no-reply@auth-service"`
+	// BaseURL is where the frontend serves the links sent by email.
+	BaseURL string `yaml:"base_url" env:"MAIL_BASE_URL" env-default:"http://localhost:3000"`
+	Dir     string `yaml:"dir" env-default:"mail"`
+	SMTP    SMTP   `yaml:"smtp"`
+}
+
+type SMTP struct {
+	Host     string `yaml:"host" env:"SMTP_HOST"`
+	Port     int    `yaml:"port" env:"SMTP_PORT" env-default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `env:"SMTP_PASSWORD"`
+	// AllowPlaintext sends mail to a relay that does not offer STARTTLS.
+	// Only for local relays such as MailHog.
+	AllowPlaintext bool `yaml:"allow_plaintext" env:"SMTP_ALLOW_PLAINTEXT"`
+}
+
+type Outbox struct {
+	PollInterval   time.Duration `yaml:"poll_interval" env-default:"2s"`
+	BatchSize      int           `yaml:"batch_size" env-default:"20"`
+	Lease          time.Duration `yaml:"lease" env-default:"5m"`
+	HandlerTimeout time.Duration `yaml:"handler_timeout" env-default:"30s"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"10"`
+	BaseBackoff    time.Duration `yaml:"base_backoff" env-default:"5s"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env-default:"1h"`
+	// Webhooks receive a JSON event for every new registration.
+	Webhooks []string `yaml:"webhooks"`
+}
+
+// Account decides who may sign in and keep using tokens. Deactivated
+// accounts are always refused. Unverified is what an account whose email
+// is not confirmed yet may do: "deny" signing in, sign in with tokens
+// limited to the "unverified" scope ("restricted"), or "allow" it fully.
+type Account struct {
+	Unverified string `yaml:"unverified" env:"ACCOUNT_UNVERIFIED" env-default:"deny"`
+}
+
+// Storage is the Postgres connection pool. When PasswordFile is set the
+// password is read from it, as from a mounted secret, so DSN can leave it
+// out; when TLSMode is set it replaces the sslmode of DSN. The server stops
+// any statement running longer than StatementTimeout. At startup the
+// connection is retried with a backoff doubling from BaseBackoff up to
+// MaxBackoff, for at most ConnectTimeout.
+type Storage struct {
+	DSN              string        `yaml:"dsn" env:"STORAGE_DSN" env-default:"postgres://postgres@localhost:5432/auth_service"`
+	PasswordFile     string        `yaml:"password_file" env:"STORAGE_PASSWORD_FILE"`
+	TLSMode          string        `yaml:"tls_mode" env:"STORAGE_TLS_MODE"`
+	MaxConns         int32         `yaml:"max_conns" env:"STORAGE_MAX_CONNS" env-default:"10"`
+	MinConns         int32         `yaml:"min_conns" env:"STORAGE_MIN_CONNS"`
+	MaxConnLifetime  time.Duration `yaml:"max_conn_lifetime" env-default:"1h"`
+	MaxConnIdleTime  time.Duration `yaml:"max_conn_idle_time" env-default:"30m"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"STORAGE_STATEMENT_TIMEOUT" env-default:"5s"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" env-default:"1m"`
+	BaseBackoff      time.Duration `yaml:"base_backoff" env-default:"500ms"`
+	MaxBackoff       time.Duration `yaml:"max_backoff" env-default:"10s"`
+}
+
+// Log controls what the service logs. Values of RedactKeys are never
+// written; JWTs and email addresses are hidden wherever they appear.
+type Log struct {
+	RedactKeys []string `yaml:"redact_keys" env:"LOG_REDACT_KEYS" env-default:"token,password,secret,authorization,cookie"`
+}
+
+// Lockout slows down password guessing. Once a login or an IP reaches its
+// threshold of failed attempts, every further failure locks it for
+// BaseDelay doubled per failure past the threshold, up to MaxDelay.
+// Counters restart after Window without failures.
+type Lockout struct {
+	LoginThreshold int           `yaml:"login_threshold" env-default:"5"`
+	IPThreshold    int           `yaml:"ip_threshold" env-default:"20"`
+	BaseDelay      time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay       time.Duration `yaml:"max_delay" env-default:"15m"`
+	Window         time.Duration `yaml:"window" env-default:"1h"`
+}
+
+// RateLimit caps how often each client may call each RPC. A call spends
+// one request from a token bucket keyed by method and client IP; the
+// bucket holds up to Burst requests and refills one every Every. Methods
+// not listed in Methods (by RPC name, e.g. "CreateUser") use Default.
+type RateLimit struct {
+	// Backend is "memory" for one instance or "postgres" to share the
+	// buckets between replicas.
+	Backend       string            `yaml:"backend" env:"RATE_LIMIT_BACKEND" env-default:"memory"`
+	PurgeInterval time.Duration     `yaml:"purge_interval" env-default:"1m"`
+	Default       Budget            `yaml:"default"`
+	Methods       map[string]Budget `yaml:"methods"`
+}
+
+// Budget is the token bucket of one RPC. A zero Every disables the limit.
+type Budget struct {
+	Every time.Duration `yaml:"every"`
+	Burst int           `yaml:"burst"`
+	// PerSubject also charges a bucket of the authenticated login when the
+	// request carries a valid access token, so one account cannot spread
+	// its calls over many addresses.
+	PerSubject bool `yaml:"per_subject"`
+}
+
+// EmailCooldown throttles RPCs that send an email on request,
+// separately for every RPC. Cooldowns live in the rate limit store, so
+// they hold across replicas only with its "postgres" backend.
+type EmailCooldown struct {
+	PerEmail time.Duration `yaml:"per_email" env-default:"5m"`
+	PerIP    time.Duration `yaml:"per_ip" env-default:"30s"`
+}
+
+// Validation limits which logins and email addresses are accepted.
+// Both are normalized to lowercase NFKC before these checks.
+type Validation struct {
+	Login LoginRules `yaml:"login"`
+	Email EmailRules `yaml:"email"`
+}
+
+type LoginRules struct {
+	MinLength int `yaml:"min_length" env-default:"3"`
+	MaxLength int `yaml:"max_length" env-default:"32"`
+	// Charset is a regular expression the whole normalized login must match.
+	Charset string `yaml:"charset" env-default:"^[a-z0-9][a-z0-9._-]*$"`
+}
+
+type EmailRules struct {
+	MaxLength int `yaml:"max_length" env-default:"254"`
+}
+
+type Password struct {
+	Algorithm string   `yaml:"algorithm" env:"PASSWORD_ALGORITHM" env-default:"bcrypt"`
+	Bcrypt    Bcrypt   `yaml:"bcrypt"`
+	Argon2id  Argon2id `yaml:"argon2id"`
+	Scrypt    Scrypt   `yaml:"scrypt"`
+	Policy    Policy   `yaml:"policy"`
+}
+
+// Policy is checked whenever a password is chosen.
+type Policy struct {
+	MinLength int `yaml:"min_length" env-default:"8"`
+	// MaxLength is in bytes: bcrypt refuses passwords longer than 72 bytes.
+	MaxLength int `yaml:"max_length" env-default:"72"`
+	// CharClasses must all appear in a password: lower, upper, digit, symbol.
+	CharClasses []string `yaml:"char_classes"`
+	// AllowIdentity lets passwords contain the login or the email address.
+	AllowIdentity bool `yaml:"allow_identity"`
+	// MinScore is the lowest accepted strength, from 0 (guessable) to 4.
+	MinScore int `yaml:"min_score" env-default:"2"`
+	// BreachedList is a directory of Pwned Passwords range files, one
+	// <5-hex-digit prefix>.txt per SHA-1 prefix. Empty disables the check.
+	BreachedList string `yaml:"breached_list" env:"PASSWORD_BREACHED_LIST"`
+}
+
+type Bcrypt struct {
+	Cost int `yaml:"cost" env-default:"10"`
+}
+
+type Argon2id struct {
+	Memory      uint32 `yaml:"memory" env-default:"65536"`
+	Iterations  uint32 `yaml:"iterations" env-default:"3"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"2"`
+	SaltLength  uint32 `yaml:"salt_length" env-default:"16"`
+	KeyLength   uint32 `yaml:"key_length" env-default:"32"`
+}
+
+type Scrypt struct {
+	N          int `yaml:"n" env-default:"32768"`
+	R          int `yaml:"r" env-default:"8"`
+	P          int `yaml:"p" env-default:"1"`
+	SaltLength int `yaml:"salt_length" env-default:"16"`
+	KeyLength  int `yaml:"key_length" env-default:"32"`
 }
 
 func MustLoad() *Config {
+	cfg, err := Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
+
+// Load reads the config without exiting on failure, so it can be re-read at runtime.
+func Load() (*Config, error) {
 	// Conditional loading of .env file or alternative configuration setup
 	// Based on project documentation, configuration is handled via YAML and CONFIG_PATH.
 	// Removing .env loading to prevent "no such file or directory" errors in containerized environments.
@@ -40,18 +281,18 @@ func MustLoad() *Config {
 		configPath = "config/local.yaml"
 	}
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("Config file does not exist at path: %s", configPath)
+		return nil, fmt.Errorf("config file does not exist at path: %s", configPath)
 	}
 
 	var cfg Config
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+		return nil, fmt.Errorf("cannot read config: %w", err)
 	}
 
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		log.Fatalf("cannot read env: %s", err)
+		return nil, fmt.Errorf("cannot read env: %w", err)
 	}
 
-	return &cfg
+	return &cfg, nil
 }
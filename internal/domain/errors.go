@@ -0,0 +1,17 @@
+package domain
+
+import "errors"
+
+// Errors shared by storage, services and the transports. Lower layers wrap
+// them with %w, so callers can tell failures apart with errors.Is.
+var (
+	ErrUserExists         = errors.New("user already exists")
+	ErrEmailTaken         = errors.New("email already taken")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrTokenExpired       = errors.New("token expired")
+	ErrTokenInvalid       = errors.New("token invalid")
+	ErrNotVerified        = errors.New("account is not verified")
+	ErrInactive           = errors.New("account is inactive")
+)
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// LoginAttempts counts the sign-in attempts of one login or client IP
+// that did not succeed, and how long the key is locked for them.
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+const (
+	OutboxPending = "pending"
+	// OutboxDead marks messages that ran out of attempts and need a human.
+	OutboxDead = "dead"
+)
+
+// OutboxMessage is a side effect recorded in the same transaction as the
+// change that caused it and delivered later by the dispatcher.
+type OutboxMessage struct {
+	Id            int64
+	Kind          string
+	Payload       []byte
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
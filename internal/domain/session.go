@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+// Session is one signed-in device. Its refresh token hash changes on every refresh.
+type Session struct {
+	Id               string
+	UserId           int64
+	RefreshTokenHash string
+	CreatedAt        time.Time
+	LastUsedAt       time.Time
+	UserAgent        string
+	IP               string
+}
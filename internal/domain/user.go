@@ -1,12 +1,21 @@
 package domain
 
 type User struct {
-	Id               int64
-	Login            string
-	Email            string
-	PasswordHash     string
-	IsActive         bool
-	IsVerified       bool
-	Role             string
-	RefreshTokenHash string
+	Id           int64
+	Login        string
+	Email        string
+	PasswordHash string
+	IsActive     bool
+	IsVerified   bool
+	Role         string
+	// TokenGeneration invalidates every token issued with a lower "gen" claim.
+	TokenGeneration int64
+}
+
+// CheckActive returns ErrInactive if the account was deactivated.
+func (u *User) CheckActive() error {
+	if !u.IsActive {
+		return ErrInactive
+	}
+	return nil
 }
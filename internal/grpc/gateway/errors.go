@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"errors"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of every errdetails.ErrorInfo sent by the gateway.
+const ErrorDomain = "auth-service"
+
+// Reasons reported in errdetails.ErrorInfo, stable for clients to switch on.
+const (
+	ReasonUserExists         = "USER_EXISTS"
+	ReasonEmailTaken         = "EMAIL_TAKEN"
+	ReasonUserNotFound       = "USER_NOT_FOUND"
+	ReasonInvalidCredentials = "INVALID_CREDENTIALS"
+	ReasonTokenExpired       = "TOKEN_EXPIRED"
+	ReasonTokenInvalid       = "TOKEN_INVALID"
+	ReasonTokenRevoked       = "TOKEN_REVOKED"
+	ReasonNotVerified        = "NOT_VERIFIED"
+	ReasonInactive           = "INACTIVE"
+)
+
+// domainErrors are the statuses of the domain errors no handler reports
+// in its own words.
+var domainErrors = []struct {
+	err    error
+	code   codes.Code
+	msg    string
+	reason string
+}{
+	{domain.ErrUserExists, codes.AlreadyExists, "login is already taken", ReasonUserExists},
+	{domain.ErrEmailTaken, codes.AlreadyExists, "email is already taken", ReasonEmailTaken},
+	{domain.ErrUserNotFound, codes.NotFound, "user not found", ReasonUserNotFound},
+	{domain.ErrInvalidCredentials, codes.Unauthenticated, "invalid login or password", ReasonInvalidCredentials},
+	{domain.ErrNotVerified, codes.FailedPrecondition, "email address is not verified", ReasonNotVerified},
+	{domain.ErrInactive, codes.PermissionDenied, "account is deactivated", ReasonInactive},
+}
+
+// withReason returns a status error carrying an ErrorInfo with reason.
+func withReason(code codes.Code, msg, reason string) error {
+	st := status.New(code, msg)
+	details := &errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain}
+	if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// tokenError reports why the token named what was refused, or returns nil
+// if err is not about a token.
+func tokenError(err error, what string) error {
+	switch {
+	case errors.Is(err, myjwt.ErrTokenRevoked):
+		return withReason(codes.Unauthenticated, what+" is no longer valid", ReasonTokenRevoked)
+	case errors.Is(err, domain.ErrTokenExpired):
+		return withReason(codes.Unauthenticated, what+" has expired", ReasonTokenExpired)
+	case errors.Is(err, domain.ErrTokenInvalid):
+		return withReason(codes.Unauthenticated, what+" is invalid", ReasonTokenInvalid)
+	}
+	return nil
+}
+
+// domainError returns the status of the domain error err wraps, or nil.
+func domainError(err error) error {
+	for _, d := range domainErrors {
+		if errors.Is(err, d.err) {
+			return withReason(d.code, d.msg, d.reason)
+		}
+	}
+	return nil
+}
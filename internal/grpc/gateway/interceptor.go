@@ -0,0 +1,153 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"path"
+	"runtime/debug"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader is the metadata key that carries the request id, both
+// in the incoming metadata and in the response header.
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLength bounds ids taken from callers, as they end up in every log line.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID returns the id AccessLog gave the request behind ctx.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ClientInfo resolves the caller of every call once, so that rate limits,
+// lockouts and sessions all key on the same address.
+func ClientInfo(resolver *clientinfo.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(clientinfo.NewContext(ctx, resolver.Resolve(ctx)), req)
+	}
+}
+
+// AccessLog takes the request id from the x-request-id metadata or makes a
+// new one, echoes it in the response header and puts a logger carrying it,
+// the method and the peer in the context for handlers and services. Every
+// call is logged with its status code and latency.
+func AccessLog(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		id := incomingRequestID(ctx)
+		ctx = context.WithValue(ctx, requestIDKey{}, id)
+		// Fails only outside a real transport, as in tests.
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, id))
+
+		reqLog := log.With(
+			slog.String("request_id", id),
+			slog.String("method", info.FullMethod),
+			slog.String("peer", clientinfo.FromContext(ctx).IP),
+		)
+		ctx = logger.NewContext(ctx, reqLog)
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		switch code {
+		case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+			level = slog.LevelError
+		}
+		reqLog.LogAttrs(ctx, level, "request finished",
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		)
+		return resp, err
+	}
+}
+
+// Recovery turns a panic in a handler into codes.Internal and logs it with
+// its stack trace.
+func Recovery(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.FromContext(ctx, log).Error("panic in handler",
+					slog.String("panic", fmt.Sprint(r)),
+					slog.String("stack", string(debug.Stack())),
+				)
+				resp, err = nil, status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func incomingRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+			return ids[0]
+		}
+	}
+	return rand.Text()
+}
+
+// validRequestID accepts printable ASCII without spaces, so a caller cannot
+// break log lines apart.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// accessTokenRequest is every request that carries an access token.
+type accessTokenRequest interface {
+	GetAccessToken() string
+}
+
+// RateLimit rejects calls over their method's budget with ResourceExhausted
+// and a RetryInfo detail. The subject of a per-subject budget is the login
+// of a valid access token in the request; an invalid one is left to the
+// handler to reject. If the store fails, the call is let through.
+func RateLimit(log *slog.Logger, limiter *ratelimit.Limiter, keys *myjwt.Keys) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method := path.Base(info.FullMethod)
+
+		var subject string
+		if limiter.Budget(method).PerSubject {
+			if r, ok := req.(accessTokenRequest); ok {
+				if claims, err := myjwt.GetClaims(r.GetAccessToken(), keys, myjwt.TokenAccess); err == nil {
+					subject = claims.Login
+				}
+			}
+		}
+
+		wait, err := limiter.Allow(ctx, method, clientinfo.FromContext(ctx).IP, subject)
+		if err != nil {
+			logger.FromContext(ctx, log).Error("rate limit check failed", logger.Err(err))
+			return handler(ctx, req)
+		}
+		if wait > 0 {
+			return nil, retryAfter(status.New(codes.ResourceExhausted, "rate limit exceeded, try again later"), wait)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -2,27 +2,59 @@ package gateway
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"log/slog"
 	"net"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	"github.com/Weit145/Auth_golang/internal/lib/cooldown"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
 	"github.com/Weit145/Auth_golang/internal/service"
+	"github.com/Weit145/Auth_golang/internal/service/changepassword"
+	"github.com/Weit145/Auth_golang/internal/service/emailchange"
+	"github.com/Weit145/Auth_golang/internal/service/introspect"
+	"github.com/Weit145/Auth_golang/internal/service/jwks"
+	"github.com/Weit145/Auth_golang/internal/service/passwordreset"
+	"github.com/Weit145/Auth_golang/internal/service/refresh"
+	"github.com/Weit145/Auth_golang/internal/service/unlock"
 	pb "github.com/Weit145/proto-repo/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
+// maxPasswordBytes bounds every password accepted from a client. It is
+// far above any sensible policy maximum, which is still reported with its
+// details, and only stops inputs meant to burn CPU in the service.
+const maxPasswordBytes = 1024
+
 type Server struct {
 	pb.UnimplementedAuthServer
 	Service service.ServiceAuth
 	Log     *slog.Logger
 }
 
-func New(Log *slog.Logger, serv service.ServiceAuth, lis net.Listener) (*grpc.Server, error) {
+func New(Log *slog.Logger, serv service.ServiceAuth, lis net.Listener, limiter *ratelimit.Limiter, keys *myjwt.Keys, clients *clientinfo.Resolver) (*grpc.Server, error) {
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		ClientInfo(clients),
+		AccessLog(Log),
+		Recovery(Log),
+		RateLimit(Log, limiter, keys),
+	))
 
 	pb.RegisterAuthServer(s, &Server{Service: serv, Log: Log})
 
@@ -36,6 +68,79 @@ func New(Log *slog.Logger, serv service.ServiceAuth, lis net.Listener) (*grpc.Se
 	return s, nil
 }
 
+// basicAuth reads HTTP Basic client credentials from the authorization
+// metadata.
+func basicAuth(ctx context.Context) (clientID, clientSecret string, ok bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", "", false
+	}
+	scheme, encoded, found := strings.Cut(values[0], " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+func refreshCookie(RefreshToken string) *pb.Cookie {
+	return &pb.Cookie{
+		Key:      "refresh_token",
+		Value:    RefreshToken,
+		Httponly: true,
+		Secure:   true,
+		Samesite: "lax",
+		MaxAge:   24,
+	}
+}
+
+// weakPassword reports every failed password rule as a BadRequest
+// field violation on field.
+func weakPassword(field string, err error) error {
+	st := status.New(codes.InvalidArgument, "password does not meet the policy")
+
+	var policyErr *passwordpolicy.Error
+	if !errors.As(err, &policyErr) {
+		return st.Err()
+	}
+	details := &errdetails.BadRequest{}
+	for _, v := range policyErr.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: v.Message,
+			Reason:      v.Rule,
+		})
+	}
+	if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// locked tells the caller how long to wait with a RetryInfo detail.
+func locked(err error) error {
+	st := status.New(codes.ResourceExhausted, "too many failed attempts, try again later")
+
+	var lockedErr *lockout.LockedError
+	if !errors.As(err, &lockedErr) {
+		return st.Err()
+	}
+	return retryAfter(st, lockedErr.RetryAfter)
+}
+
+// retryAfter attaches wait to st as a RetryInfo detail.
+func retryAfter(st *status.Status, wait time.Duration) error {
+	details := &errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}
+	if withDetails, detailsErr := st.WithDetails(details); detailsErr == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
 func (s *Server) CreateUser(ctx context.Context, req *pb.UserCreateRequest) (*pb.Okey, error) {
 	login := req.GetLogin()
 	email := req.GetEmail()
@@ -50,10 +155,25 @@ func (s *Server) CreateUser(ctx context.Context, req *pb.UserCreateRequest) (*pb
 	if password == "" {
 		return nil, status.Error(codes.InvalidArgument, "password is required")
 	}
+	if len(password) > maxPasswordBytes {
+		return nil, status.Error(codes.InvalidArgument, "password is too long")
+	}
 
-	s.Log.Info("Calling Service.CreateUser", slog.String("login", login), slog.String("email", email))
+	logger.FromContext(ctx, s.Log).Info("Calling Service.CreateUser", slog.String("login", login), slog.String("email", email))
 	err := s.Service.CreateUser(ctx, login, email, password)
 	if err != nil {
+		if errors.Is(err, identity.ErrInvalidLogin) {
+			return nil, status.Error(codes.InvalidArgument, "login is invalid")
+		}
+		if errors.Is(err, identity.ErrInvalidEmail) {
+			return nil, status.Error(codes.InvalidArgument, "email is invalid")
+		}
+		if errors.Is(err, passwordpolicy.ErrWeakPassword) {
+			return nil, weakPassword("password", err)
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, "failed to create user")
 	}
 	resp := pb.Okey{Success: true}
@@ -67,18 +187,20 @@ func (s *Server) RegistrationUser(ctx context.Context, req *pb.TokenRequest) (*p
 	}
 	AssetToken, RefreshToken, err := s.Service.Confirm(ctx, token)
 	if err != nil {
+		if errors.Is(err, myjwt.ErrTokenRevoked) {
+			return nil, withReason(codes.Unauthenticated, "confirmation link has already been used", ReasonTokenRevoked)
+		}
+		if st := tokenError(err, "confirmation link"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, "failed to confirm user")
 	}
 	resp := pb.CookieResponse{
 		AccessToken: AssetToken,
-		Cookie: &pb.Cookie{
-			Key:      "refresh_token",
-			Value:    RefreshToken,
-			Httponly: true,
-			Secure:   true,
-			Samesite: "lax",
-			MaxAge:   24,
-		},
+		Cookie:      refreshCookie(RefreshToken),
 	}
 	return &resp, nil
 }
@@ -88,12 +210,22 @@ func (s *Server) RefreshToken(ctx context.Context, req *pb.CookieRequest) (*pb.A
 	if RefreshToken == "" {
 		return nil, status.Error(codes.InvalidArgument, "RefreshToken is required")
 	}
-	AssetToken, err := s.Service.Refresh(ctx, RefreshToken)
+	AssetToken, NewRefreshToken, err := s.Service.Refresh(ctx, RefreshToken)
 	if err != nil {
+		if errors.Is(err, refresh.ErrRefreshTokenReused) || errors.Is(err, refresh.ErrInvalidRefreshToken) {
+			return nil, withReason(codes.Unauthenticated, "refresh token is no longer valid", ReasonTokenRevoked)
+		}
+		if st := tokenError(err, "refresh token"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, "failed to refresh token")
 	}
 	resp := pb.AccessTokenResponse{
 		AccessToken: AssetToken,
+		Cookie:      refreshCookie(NewRefreshToken),
 	}
 	return &resp, nil
 }
@@ -107,20 +239,22 @@ func (s *Server) Authenticate(ctx context.Context, req *pb.UserLoginRequest) (*p
 	if password == "" {
 		return nil, status.Error(codes.InvalidArgument, "Password is required")
 	}
+	if len(password) > maxPasswordBytes {
+		return nil, status.Error(codes.InvalidArgument, "Password is too long")
+	}
 	AccessToken, RefreshToken, err := s.Service.LoginUser(ctx, login, password)
 	if err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			return nil, locked(err)
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, "failed to authenticate user")
 	}
 	resp := pb.CookieResponse{
 		AccessToken: AccessToken,
-		Cookie: &pb.Cookie{
-			Key:      "refresh_token",
-			Value:    RefreshToken,
-			Httponly: true,
-			Secure:   true,
-			Samesite: "lax",
-			MaxAge:   24,
-		},
+		Cookie:      refreshCookie(RefreshToken),
 	}
 	return &resp, nil
 }
@@ -132,6 +266,12 @@ func (s *Server) CurrentUser(ctx context.Context, req *pb.UserCurrentRequest) (*
 	}
 	user, err := s.Service.Current(ctx, AssetToken)
 	if err != nil {
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, "failed to get current user")
 	}
 	resp := pb.CurrentUserResponse{
@@ -152,9 +292,399 @@ func (s *Server) LogOutUser(ctx context.Context, req *pb.TokenRequest) (*pb.Empt
 
 	err := s.Service.LogOutUser(ctx, AssetToken)
 	if err != nil {
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
 		return nil, status.Error(codes.Internal, "failed to Log out user")
 	}
 
 	resp := pb.Empty{}
 	return &resp, nil
 }
+
+func (s *Server) GetJWKS(ctx context.Context, req *pb.Empty) (*pb.JWKSResponse, error) {
+	set := s.Service.PublicKeys(ctx)
+
+	resp := pb.JWKSResponse{Keys: make([]*pb.JWK, 0, len(set.Keys))}
+	for _, k := range set.Keys {
+		resp.Keys = append(resp.Keys, &pb.JWK{
+			Kty: k.Kty,
+			Kid: k.Kid,
+			Use: k.Use,
+			Alg: k.Alg,
+			N:   k.N,
+			E:   k.E,
+			Crv: k.Crv,
+			X:   k.X,
+			Y:   k.Y,
+		})
+	}
+	return &resp, nil
+}
+
+func (s *Server) RotateSigningKeys(ctx context.Context, req *pb.TokenRequest) (*pb.Empty, error) {
+	AssetToken := req.GetTokenPod()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+
+	err := s.Service.RotateSigningKeys(ctx, AssetToken)
+	if err != nil {
+		if errors.Is(err, jwks.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, "admin role required")
+		}
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to rotate signing keys")
+	}
+
+	resp := pb.Empty{}
+	return &resp, nil
+}
+
+func (s *Server) ListSessions(ctx context.Context, req *pb.UserCurrentRequest) (*pb.SessionsResponse, error) {
+	AssetToken := req.GetAccessToken()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+
+	list, err := s.Service.ListSessions(ctx, AssetToken)
+	if err != nil {
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to list sessions")
+	}
+
+	resp := pb.SessionsResponse{Sessions: make([]*pb.Session, 0, len(list))}
+	for _, session := range list {
+		resp.Sessions = append(resp.Sessions, &pb.Session{
+			Id:         session.Id,
+			UserAgent:  session.UserAgent,
+			Ip:         session.IP,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastUsedAt: session.LastUsedAt.Unix(),
+			Current:    session.Current,
+		})
+	}
+	return &resp, nil
+}
+
+func (s *Server) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.Empty, error) {
+	AssetToken := req.GetAccessToken()
+	sessionID := req.GetSessionId()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+	if sessionID == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+
+	err := s.Service.RevokeSession(ctx, AssetToken, sessionID)
+	if err != nil {
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to revoke session")
+	}
+
+	resp := pb.Empty{}
+	return &resp, nil
+}
+
+func (s *Server) RevokeOtherSessions(ctx context.Context, req *pb.UserCurrentRequest) (*pb.CookieResponse, error) {
+	AssetToken := req.GetAccessToken()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+
+	AccessToken, RefreshToken, err := s.Service.RevokeOtherSessions(ctx, AssetToken)
+	if err != nil {
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to revoke sessions")
+	}
+
+	resp := pb.CookieResponse{
+		AccessToken: AccessToken,
+		Cookie:      refreshCookie(RefreshToken),
+	}
+	return &resp, nil
+}
+
+func (s *Server) Introspect(ctx context.Context, req *pb.IntrospectRequest) (*pb.IntrospectResponse, error) {
+	token := req.GetToken()
+	if token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	clientID, clientSecret, ok := basicAuth(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "client credentials are required")
+	}
+
+	result, err := s.Service.Introspect(ctx, clientID, clientSecret, token)
+	if err != nil {
+		if errors.Is(err, introspect.ErrUnauthorizedClient) {
+			return nil, status.Error(codes.Unauthenticated, "invalid client credentials")
+		}
+		return nil, status.Error(codes.Internal, "failed to introspect token")
+	}
+	if !result.Active {
+		return &pb.IntrospectResponse{Active: false}, nil
+	}
+
+	resp := pb.IntrospectResponse{
+		Active:    true,
+		Scope:     result.Scope,
+		Username:  result.Username,
+		TokenType: result.TokenType,
+		Exp:       result.ExpiresAt.Unix(),
+		Iat:       result.IssuedAt.Unix(),
+		Sub:       result.Subject,
+		Jti:       result.ID,
+		Sid:       result.SessionID,
+	}
+	return &resp, nil
+}
+
+func (s *Server) ResendVerification(ctx context.Context, req *pb.EmailRequest) (*pb.Okey, error) {
+	email := req.GetEmail()
+	if email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	err := s.Service.ResendVerification(ctx, email)
+	if err != nil {
+		if errors.Is(err, cooldown.ErrTooManyRequests) {
+			return nil, status.Error(codes.ResourceExhausted, "please wait before requesting another email")
+		}
+		if errors.Is(err, identity.ErrInvalidEmail) {
+			return nil, status.Error(codes.InvalidArgument, "email is invalid")
+		}
+		return nil, status.Error(codes.Internal, "failed to resend verification email")
+	}
+
+	resp := pb.Okey{Success: true}
+	return &resp, nil
+}
+
+func (s *Server) RequestPasswordReset(ctx context.Context, req *pb.EmailRequest) (*pb.Okey, error) {
+	email := req.GetEmail()
+	if email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	err := s.Service.RequestPasswordReset(ctx, email)
+	if err != nil {
+		if errors.Is(err, cooldown.ErrTooManyRequests) {
+			return nil, status.Error(codes.ResourceExhausted, "please wait before requesting another email")
+		}
+		if errors.Is(err, identity.ErrInvalidEmail) {
+			return nil, status.Error(codes.InvalidArgument, "email is invalid")
+		}
+		return nil, status.Error(codes.Internal, "failed to request password reset")
+	}
+
+	resp := pb.Okey{Success: true}
+	return &resp, nil
+}
+
+func (s *Server) ResetPassword(ctx context.Context, req *pb.ResetPasswordRequest) (*pb.Okey, error) {
+	token := req.GetToken()
+	newPassword := req.GetNewPassword()
+	if token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if newPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password is required")
+	}
+	if len(newPassword) > maxPasswordBytes {
+		return nil, status.Error(codes.InvalidArgument, "new password is too long")
+	}
+
+	err := s.Service.ResetPassword(ctx, token, newPassword)
+	if err != nil {
+		if errors.Is(err, passwordreset.ErrInvalidResetToken) {
+			return nil, status.Error(codes.InvalidArgument, "reset link is invalid or has expired")
+		}
+		if errors.Is(err, passwordpolicy.ErrWeakPassword) {
+			return nil, weakPassword("new_password", err)
+		}
+		return nil, status.Error(codes.Internal, "failed to reset password")
+	}
+
+	resp := pb.Okey{Success: true}
+	return &resp, nil
+}
+
+// ChangePassword returns an empty CookieResponse unless the current session
+// was kept, in which case it carries the session's new tokens.
+func (s *Server) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.CookieResponse, error) {
+	AssetToken := req.GetAccessToken()
+	oldPassword := req.GetOldPassword()
+	newPassword := req.GetNewPassword()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+	if oldPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "old password is required")
+	}
+	if newPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "new password is required")
+	}
+	if len(oldPassword) > maxPasswordBytes || len(newPassword) > maxPasswordBytes {
+		return nil, status.Error(codes.InvalidArgument, "password is too long")
+	}
+
+	AccessToken, RefreshToken, err := s.Service.ChangePassword(ctx, AssetToken, oldPassword, newPassword, req.GetKeepCurrentSession())
+	if err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			return nil, locked(err)
+		}
+		if errors.Is(err, changepassword.ErrWrongPassword) {
+			return nil, withReason(codes.Unauthenticated, "current password is wrong", ReasonInvalidCredentials)
+		}
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, passwordpolicy.ErrWeakPassword) {
+			return nil, weakPassword("new_password", err)
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to change password")
+	}
+	if !req.GetKeepCurrentSession() {
+		return &pb.CookieResponse{}, nil
+	}
+
+	resp := pb.CookieResponse{
+		AccessToken: AccessToken,
+		Cookie:      refreshCookie(RefreshToken),
+	}
+	return &resp, nil
+}
+
+func (s *Server) RequestEmailChange(ctx context.Context, req *pb.EmailChangeRequest) (*pb.Okey, error) {
+	AssetToken := req.GetAccessToken()
+	newEmail := req.GetNewEmail()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+	if newEmail == "" {
+		return nil, status.Error(codes.InvalidArgument, "new email is required")
+	}
+
+	err := s.Service.RequestEmailChange(ctx, AssetToken, newEmail)
+	if err != nil {
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, identity.ErrInvalidEmail) {
+			return nil, status.Error(codes.InvalidArgument, "new email is invalid")
+		}
+		if errors.Is(err, emailchange.ErrSameEmail) {
+			return nil, status.Error(codes.InvalidArgument, "new email is the current email")
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to request email change")
+	}
+
+	resp := pb.Okey{Success: true}
+	return &resp, nil
+}
+
+func (s *Server) ConfirmEmailChange(ctx context.Context, req *pb.TokenRequest) (*pb.Okey, error) {
+	token := req.GetTokenPod()
+	if token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	err := s.Service.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		if errors.Is(err, emailchange.ErrInvalidEmailChangeToken) {
+			return nil, status.Error(codes.InvalidArgument, "confirmation link is invalid or has expired")
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to confirm email change")
+	}
+
+	resp := pb.Okey{Success: true}
+	return &resp, nil
+}
+
+func (s *Server) CancelEmailChange(ctx context.Context, req *pb.TokenRequest) (*pb.Okey, error) {
+	token := req.GetTokenPod()
+	if token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	err := s.Service.CancelEmailChange(ctx, token)
+	if err != nil {
+		if errors.Is(err, emailchange.ErrInvalidEmailChangeToken) {
+			return nil, status.Error(codes.InvalidArgument, "cancel link is invalid or the change is no longer pending")
+		}
+		return nil, status.Error(codes.Internal, "failed to cancel email change")
+	}
+
+	resp := pb.Okey{Success: true}
+	return &resp, nil
+}
+
+func (s *Server) UnlockAccount(ctx context.Context, req *pb.UnlockRequest) (*pb.Empty, error) {
+	AssetToken := req.GetAccessToken()
+	login := req.GetLogin()
+	ip := req.GetIp()
+	if AssetToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "AssetToken is required")
+	}
+	if login == "" && ip == "" {
+		return nil, status.Error(codes.InvalidArgument, "login or ip is required")
+	}
+
+	err := s.Service.UnlockAccount(ctx, AssetToken, login, ip)
+	if err != nil {
+		if errors.Is(err, unlock.ErrForbidden) {
+			return nil, status.Error(codes.PermissionDenied, "admin role required")
+		}
+		if st := tokenError(err, "access token"); st != nil {
+			return nil, st
+		}
+		if errors.Is(err, identity.ErrInvalidLogin) {
+			return nil, status.Error(codes.InvalidArgument, "login is invalid")
+		}
+		if st := domainError(err); st != nil {
+			return nil, st
+		}
+		return nil, status.Error(codes.Internal, "failed to unlock account")
+	}
+
+	resp := pb.Empty{}
+	return &resp, nil
+}
@@ -1,18 +1,49 @@
 package gateway_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
 	"github.com/Weit145/Auth_golang/internal/grpc/gateway"
+	"github.com/Weit145/Auth_golang/internal/lib/cooldown"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
 	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+	"github.com/Weit145/Auth_golang/internal/service/changepassword"
 	"github.com/Weit145/Auth_golang/internal/service/current"
+	"github.com/Weit145/Auth_golang/internal/service/emailchange"
+	"github.com/Weit145/Auth_golang/internal/service/introspect"
+	"github.com/Weit145/Auth_golang/internal/service/jwks"
 	"github.com/Weit145/Auth_golang/internal/service/mocks"
+	"github.com/Weit145/Auth_golang/internal/service/passwordreset"
+	"github.com/Weit145/Auth_golang/internal/service/refresh"
+	"github.com/Weit145/Auth_golang/internal/service/sessions"
+	"github.com/Weit145/Auth_golang/internal/service/unlock"
 	pb "github.com/Weit145/proto-repo/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 func newTestServer(t *testing.T, svc *mocks.ServiceAuth) *gateway.Server {
@@ -59,6 +90,58 @@ func TestCreateUser_Unit(t *testing.T) {
 			email:       "test@example.com",
 			expectedErr: "password is required",
 		},
+		{
+			name:        "oversized password",
+			login:       "test_user",
+			email:       "test@example.com",
+			password:    strings.Repeat("a", 100000),
+			expectedErr: "password is too long",
+		},
+		{
+			name:          "invalid login",
+			login:         "test user",
+			email:         "test@example.com",
+			password:      "password123",
+			mockError:     fmt.Errorf("service.CreateUser: %w", identity.ErrInvalidLogin),
+			expectedErr:   "login is invalid",
+			serviceCalled: true,
+		},
+		{
+			name:          "invalid email",
+			login:         "test_user",
+			email:         "test@example",
+			password:      "password123",
+			mockError:     fmt.Errorf("service.CreateUser: %w", identity.ErrInvalidEmail),
+			expectedErr:   "email is invalid",
+			serviceCalled: true,
+		},
+		{
+			name:          "weak password",
+			login:         "test_user",
+			email:         "test@example.com",
+			password:      "short",
+			mockError:     fmt.Errorf("service.CreateUser: %w", passwordpolicy.ErrWeakPassword),
+			expectedErr:   "password does not meet the policy",
+			serviceCalled: true,
+		},
+		{
+			name:          "login taken",
+			login:         "test_user",
+			email:         "test@example.com",
+			password:      "password123",
+			mockError:     fmt.Errorf("service.CreateUser: %w", domain.ErrUserExists),
+			expectedErr:   "login is already taken",
+			serviceCalled: true,
+		},
+		{
+			name:          "email taken",
+			login:         "test_user",
+			email:         "test@example.com",
+			password:      "password123",
+			mockError:     fmt.Errorf("service.CreateUser: %w", domain.ErrEmailTaken),
+			expectedErr:   "email is already taken",
+			serviceCalled: true,
+		},
 		{
 			name:          "Service error",
 			login:         "test_user",
@@ -109,6 +192,41 @@ func TestCreateUser_Unit(t *testing.T) {
 	}
 }
 
+func TestCreateUser_WeakPasswordDetails(t *testing.T) {
+	mockService := mocks.NewServiceAuth(t)
+	policyErr := &passwordpolicy.Error{Violations: []passwordpolicy.Violation{
+		{Rule: passwordpolicy.RuleMinLength, Message: "shorter than 8 characters"},
+		{Rule: passwordpolicy.RuleContainsLogin, Message: "contains the login"},
+	}}
+	mockService.On("CreateUser", mock.Anything, "test_user", "test@example.com", "test_user").
+		Return(fmt.Errorf("service.CreateUser: %w", policyErr)).Once()
+
+	srv := newTestServer(t, mockService)
+
+	resp, err := srv.CreateUser(context.Background(), &pb.UserCreateRequest{
+		Login:    "test_user",
+		Email:    "test@example.com",
+		Password: "test_user",
+	})
+	require.Nil(t, resp)
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, badRequest.GetFieldViolations(), 2)
+	for i, v := range badRequest.GetFieldViolations() {
+		require.Equal(t, "password", v.GetField())
+		require.Equal(t, policyErr.Violations[i].Rule, v.GetReason())
+		require.Equal(t, policyErr.Violations[i].Message, v.GetDescription())
+	}
+
+	mockService.AssertExpectations(t)
+}
+
 func TestRegistrationUser_Unit(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -132,6 +250,13 @@ func TestRegistrationUser_Unit(t *testing.T) {
 			expectedErr:   "token is required",
 			serviceCalled: false,
 		},
+		{
+			name:          "link already used",
+			token:         "used_token",
+			mockError:     fmt.Errorf("service.Confirm: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "confirmation link has already been used",
+			serviceCalled: true,
+		},
 		{
 			name:          "Service error",
 			token:         "valid_token",
@@ -190,6 +315,7 @@ func TestRefreshToken_Unit(t *testing.T) {
 		name          string
 		refreshToken  string
 		mockAsset     string
+		mockRefresh   string
 		mockError     error
 		expectedErr   string
 		serviceCalled bool
@@ -198,6 +324,7 @@ func TestRefreshToken_Unit(t *testing.T) {
 			name:          "success",
 			refreshToken:  "valid_refresh_token",
 			mockAsset:     "new_access_token",
+			mockRefresh:   "new_refresh_token",
 			serviceCalled: true,
 		},
 		{
@@ -214,6 +341,13 @@ func TestRefreshToken_Unit(t *testing.T) {
 			expectedErr:   "failed to refresh token",
 			serviceCalled: true,
 		},
+		{
+			name:          "reused refresh token",
+			refreshToken:  "rotated_refresh_token",
+			mockError:     fmt.Errorf("service.Refresh: %w", refresh.ErrRefreshTokenReused),
+			expectedErr:   "refresh token is no longer valid",
+			serviceCalled: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -223,7 +357,7 @@ func TestRefreshToken_Unit(t *testing.T) {
 
 			if tc.serviceCalled {
 				mockService.On("Refresh", mock.Anything, tc.refreshToken).
-					Return(tc.mockAsset, tc.mockError).Once()
+					Return(tc.mockAsset, tc.mockRefresh, tc.mockError).Once()
 			}
 
 			srv := newTestServer(t, mockService)
@@ -242,6 +376,10 @@ func TestRefreshToken_Unit(t *testing.T) {
 				require.NoError(t, err)
 				require.NotNil(t, resp)
 				require.Equal(t, tc.mockAsset, resp.AccessToken)
+				require.NotNil(t, resp.Cookie)
+				require.Equal(t, "refresh_token", resp.Cookie.Key)
+				require.Equal(t, tc.mockRefresh, resp.Cookie.Value)
+				require.True(t, resp.Cookie.Httponly)
 			}
 
 			if !tc.serviceCalled {
@@ -296,6 +434,38 @@ func TestAuthenticate_Unit(t *testing.T) {
 			expectedErr:   "failed to authenticate user",
 			serviceCalled: true,
 		},
+		{
+			name:          "invalid credentials",
+			login:         "test_login",
+			password:      "wrong_password",
+			mockError:     fmt.Errorf("service.LoginUser: %w", domain.ErrInvalidCredentials),
+			expectedErr:   "invalid login or password",
+			serviceCalled: true,
+		},
+		{
+			name:          "locked out",
+			login:         "test_login",
+			password:      "test_password",
+			mockError:     fmt.Errorf("service.LoginUser: %w", &lockout.LockedError{RetryAfter: time.Minute}),
+			expectedErr:   "too many failed attempts",
+			serviceCalled: true,
+		},
+		{
+			name:          "email not verified",
+			login:         "test_login",
+			password:      "test_password",
+			mockError:     fmt.Errorf("service.LoginUser: %w", domain.ErrNotVerified),
+			expectedErr:   "email address is not verified",
+			serviceCalled: true,
+		},
+		{
+			name:          "account deactivated",
+			login:         "test_login",
+			password:      "test_password",
+			mockError:     fmt.Errorf("service.LoginUser: %w", domain.ErrInactive),
+			expectedErr:   "account is deactivated",
+			serviceCalled: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -343,6 +513,31 @@ func TestAuthenticate_Unit(t *testing.T) {
 	}
 }
 
+func TestAuthenticate_LockedRetryInfo(t *testing.T) {
+	mockService := mocks.NewServiceAuth(t)
+	mockService.On("LoginUser", mock.Anything, "test_login", "test_password").
+		Return("", "", fmt.Errorf("service.LoginUser: %w", &lockout.LockedError{RetryAfter: 90 * time.Second})).Once()
+
+	srv := newTestServer(t, mockService)
+
+	resp, err := srv.Authenticate(context.Background(), &pb.UserLoginRequest{
+		Login:    "test_login",
+		Password: "test_password",
+	})
+	require.Nil(t, resp)
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.Equal(t, 90*time.Second, retryInfo.GetRetryDelay().AsDuration())
+
+	mockService.AssertExpectations(t)
+}
+
 func TestCurrentUser_Unit(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -370,6 +565,34 @@ func TestCurrentUser_Unit(t *testing.T) {
 			expectedErr:   "AssetToken is required",
 			serviceCalled: false,
 		},
+		{
+			name:          "revoked token",
+			accessToken:   "old_access_token",
+			mockError:     fmt.Errorf("service.Current: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
+		{
+			name:          "expired token",
+			accessToken:   "expired_access_token",
+			mockError:     fmt.Errorf("service.Current: %w", domain.ErrTokenExpired),
+			expectedErr:   "access token has expired",
+			serviceCalled: true,
+		},
+		{
+			name:          "malformed token",
+			accessToken:   "garbage",
+			mockError:     fmt.Errorf("service.Current: %w", domain.ErrTokenInvalid),
+			expectedErr:   "access token is invalid",
+			serviceCalled: true,
+		},
+		{
+			name:          "user deleted",
+			accessToken:   "valid_access_token",
+			mockError:     fmt.Errorf("service.Current: %w", domain.ErrUserNotFound),
+			expectedErr:   "user not found",
+			serviceCalled: true,
+		},
 		{
 			name:          "Service error",
 			accessToken:   "valid_access_token",
@@ -440,6 +663,13 @@ func TestLogOutUser_Unit(t *testing.T) {
 			expectedErr:   "AssetToken is required",
 			serviceCalled: false,
 		},
+		{
+			name:          "already logged out",
+			accessToken:   "revoked_access_token",
+			mockError:     fmt.Errorf("service.LogOutUser: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
 		{
 			name:          "Service error",
 			accessToken:   "valid_access_token",
@@ -484,3 +714,1441 @@ func TestLogOutUser_Unit(t *testing.T) {
 		})
 	}
 }
+
+func TestGetJWKS_Unit(t *testing.T) {
+	mockService := mocks.NewServiceAuth(t)
+	mockService.On("PublicKeys", mock.Anything).
+		Return(myjwt.JWKS{Keys: []myjwt.JWK{
+			{Kty: "OKP", Kid: "kid-1", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: "x-value"},
+		}}).Once()
+
+	srv := newTestServer(t, mockService)
+
+	resp, err := srv.GetJWKS(context.Background(), &pb.Empty{})
+
+	require.NoError(t, err)
+	require.Len(t, resp.Keys, 1)
+	require.Equal(t, "kid-1", resp.Keys[0].Kid)
+	require.Equal(t, "EdDSA", resp.Keys[0].Alg)
+	require.Equal(t, "Ed25519", resp.Keys[0].Crv)
+	require.Equal(t, "x-value", resp.Keys[0].X)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRotateSigningKeys_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		accessToken   string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			accessToken:   "admin_access_token",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "not admin",
+			accessToken:   "user_access_token",
+			mockError:     fmt.Errorf("service.RotateSigningKeys: %w", jwks.ErrForbidden),
+			expectedErr:   "admin role required",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "admin_access_token",
+			mockError:     errors.New("bad key file"),
+			expectedErr:   "failed to rotate signing keys",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("RotateSigningKeys", mock.Anything, tc.accessToken).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.TokenRequest{
+				TokenPod: tc.accessToken,
+			}
+
+			resp, err := srv.RotateSigningKeys(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, resp)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "RotateSigningKeys", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListSessions_Unit(t *testing.T) {
+	lastUsed := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name          string
+		accessToken   string
+		mockSessions  []sessions.Session
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:        "success",
+			accessToken: "valid_access_token",
+			mockSessions: []sessions.Session{
+				{Id: "sid-1", UserAgent: "curl/8.0", IP: "10.0.0.1", LastUsedAt: lastUsed, Current: true},
+				{Id: "sid-2", UserAgent: "Firefox", IP: "10.0.0.2", LastUsedAt: lastUsed},
+			},
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "revoked token",
+			accessToken:   "old_access_token",
+			mockError:     fmt.Errorf("service.ListSessions: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "valid_access_token",
+			mockError:     errors.New("service list sessions error"),
+			expectedErr:   "failed to list sessions",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("ListSessions", mock.Anything, tc.accessToken).
+					Return(tc.mockSessions, tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.UserCurrentRequest{
+				AccessToken: tc.accessToken,
+			}
+
+			resp, err := srv.ListSessions(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, resp.Sessions, len(tc.mockSessions))
+				require.Equal(t, "sid-1", resp.Sessions[0].Id)
+				require.Equal(t, "10.0.0.1", resp.Sessions[0].Ip)
+				require.Equal(t, lastUsed.Unix(), resp.Sessions[0].LastUsedAt)
+				require.True(t, resp.Sessions[0].Current)
+				require.False(t, resp.Sessions[1].Current)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "ListSessions", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeSession_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		accessToken   string
+		sessionID     string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			accessToken:   "valid_access_token",
+			sessionID:     "sid-2",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			sessionID:     "sid-2",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "empty session id",
+			accessToken:   "valid_access_token",
+			sessionID:     "",
+			expectedErr:   "session id is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "unknown session",
+			accessToken:   "valid_access_token",
+			sessionID:     "someone-elses-sid",
+			mockError:     fmt.Errorf("service.RevokeSession: %w", domain.ErrSessionNotFound),
+			expectedErr:   "session not found",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "valid_access_token",
+			sessionID:     "sid-2",
+			mockError:     errors.New("service revoke session error"),
+			expectedErr:   "failed to revoke session",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("RevokeSession", mock.Anything, tc.accessToken, tc.sessionID).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.RevokeSessionRequest{
+				AccessToken: tc.accessToken,
+				SessionId:   tc.sessionID,
+			}
+
+			resp, err := srv.RevokeSession(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, resp)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeOtherSessions_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		accessToken   string
+		mockAccess    string
+		mockRefresh   string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			accessToken:   "valid_access_token",
+			mockAccess:    "new_access_token",
+			mockRefresh:   "new_refresh_token",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "revoked token",
+			accessToken:   "old_access_token",
+			mockError:     fmt.Errorf("service.RevokeOtherSessions: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "valid_access_token",
+			mockError:     errors.New("service revoke sessions error"),
+			expectedErr:   "failed to revoke sessions",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("RevokeOtherSessions", mock.Anything, tc.accessToken).
+					Return(tc.mockAccess, tc.mockRefresh, tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.UserCurrentRequest{
+				AccessToken: tc.accessToken,
+			}
+
+			resp, err := srv.RevokeOtherSessions(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.mockAccess, resp.AccessToken)
+				require.Equal(t, tc.mockRefresh, resp.Cookie.Value)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "RevokeOtherSessions", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIntrospect_Unit(t *testing.T) {
+	issued := time.Unix(1700000000, 0)
+
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		mockResult    *introspect.Result
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "active",
+			token:         "valid_access_token",
+			authorization: basic("resource-server:s3cret"),
+			mockResult: &introspect.Result{
+				Active:    true,
+				Subject:   "test_user",
+				Username:  "test_user",
+				Scope:     "user",
+				TokenType: "access",
+				SessionID: "sid-1",
+				ID:        "jti-1",
+				IssuedAt:  issued,
+				ExpiresAt: issued.Add(time.Hour),
+			},
+			serviceCalled: true,
+		},
+		{
+			name:          "inactive",
+			token:         "expired_access_token",
+			authorization: basic("resource-server:s3cret"),
+			mockResult:    &introspect.Result{},
+			serviceCalled: true,
+		},
+		{
+			name:          "empty token",
+			token:         "",
+			authorization: basic("resource-server:s3cret"),
+			expectedErr:   "token is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "Service error",
+			token:         "valid_access_token",
+			authorization: basic("resource-server:s3cret"),
+			mockError:     errors.New("service introspect error"),
+			expectedErr:   "failed to introspect token",
+			serviceCalled: true,
+		},
+		{
+			name:          "missing client credentials",
+			token:         "valid_access_token",
+			expectedErr:   "client credentials are required",
+			serviceCalled: false,
+		},
+		{
+			name:          "not basic credentials",
+			token:         "valid_access_token",
+			authorization: "Bearer valid_access_token",
+			expectedErr:   "client credentials are required",
+			serviceCalled: false,
+		},
+		{
+			name:          "unauthorized client",
+			token:         "valid_access_token",
+			authorization: basic("resource-server:wrong"),
+			mockError:     fmt.Errorf("service.Introspect: %w", introspect.ErrUnauthorizedClient),
+			expectedErr:   "invalid client credentials",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("Introspect", mock.Anything, "resource-server", mock.Anything, tc.token).
+					Return(tc.mockResult, tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.IntrospectRequest{
+				Token: tc.token,
+			}
+
+			ctx := context.Background()
+			if tc.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tc.authorization))
+			}
+			resp, err := srv.Introspect(ctx, req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else if !tc.mockResult.Active {
+				require.NoError(t, err)
+				require.False(t, resp.Active)
+				require.Empty(t, resp.Sub)
+				require.Zero(t, resp.Exp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Active)
+				require.Equal(t, "test_user", resp.Sub)
+				require.Equal(t, "access", resp.TokenType)
+				require.Equal(t, "sid-1", resp.Sid)
+				require.Equal(t, "jti-1", resp.Jti)
+				require.Equal(t, issued.Unix(), resp.Iat)
+				require.Equal(t, issued.Add(time.Hour).Unix(), resp.Exp)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "Introspect", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResendVerification_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		email         string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			email:         "test@example.com",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty email",
+			email:         "",
+			expectedErr:   "email is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "cooldown",
+			email:         "test@example.com",
+			mockError:     fmt.Errorf("service.ResendVerification: %w", cooldown.ErrTooManyRequests),
+			expectedErr:   "please wait before requesting another email",
+			serviceCalled: true,
+		},
+		{
+			name:          "invalid email",
+			email:         "not an email",
+			mockError:     fmt.Errorf("service.ResendVerification: %w", identity.ErrInvalidEmail),
+			expectedErr:   "email is invalid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			email:         "test@example.com",
+			mockError:     errors.New("service resend error"),
+			expectedErr:   "failed to resend verification email",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("ResendVerification", mock.Anything, tc.email).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.EmailRequest{
+				Email: tc.email,
+			}
+
+			resp, err := srv.ResendVerification(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Success)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "ResendVerification", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestPasswordReset_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		email         string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			email:         "test@example.com",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty email",
+			email:         "",
+			expectedErr:   "email is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "cooldown",
+			email:         "test@example.com",
+			mockError:     fmt.Errorf("service.RequestPasswordReset: %w", cooldown.ErrTooManyRequests),
+			expectedErr:   "please wait before requesting another email",
+			serviceCalled: true,
+		},
+		{
+			name:          "invalid email",
+			email:         "not an email",
+			mockError:     fmt.Errorf("service.RequestPasswordReset: %w", identity.ErrInvalidEmail),
+			expectedErr:   "email is invalid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			email:         "test@example.com",
+			mockError:     errors.New("service reset error"),
+			expectedErr:   "failed to request password reset",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("RequestPasswordReset", mock.Anything, tc.email).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.EmailRequest{
+				Email: tc.email,
+			}
+
+			resp, err := srv.RequestPasswordReset(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Success)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "RequestPasswordReset", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResetPassword_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		newPassword   string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			token:         "reset_token",
+			newPassword:   "new_password",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty token",
+			token:         "",
+			newPassword:   "new_password",
+			expectedErr:   "token is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "empty password",
+			token:         "reset_token",
+			newPassword:   "",
+			expectedErr:   "new password is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "used or expired token",
+			token:         "used_token",
+			newPassword:   "new_password",
+			mockError:     fmt.Errorf("service.ResetPassword: %w", passwordreset.ErrInvalidResetToken),
+			expectedErr:   "reset link is invalid or has expired",
+			serviceCalled: true,
+		},
+		{
+			name:          "weak password",
+			token:         "reset_token",
+			newPassword:   "short",
+			mockError:     fmt.Errorf("service.ResetPassword: %w", passwordpolicy.ErrWeakPassword),
+			expectedErr:   "password does not meet the policy",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			token:         "reset_token",
+			newPassword:   "new_password",
+			mockError:     errors.New("service reset error"),
+			expectedErr:   "failed to reset password",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("ResetPassword", mock.Anything, tc.token, tc.newPassword).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.ResetPasswordRequest{
+				Token:       tc.token,
+				NewPassword: tc.newPassword,
+			}
+
+			resp, err := srv.ResetPassword(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Success)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangePassword_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		accessToken   string
+		oldPassword   string
+		newPassword   string
+		keepCurrent   bool
+		mockAccess    string
+		mockRefresh   string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			accessToken:   "valid_access_token",
+			oldPassword:   "old_password",
+			newPassword:   "new_password",
+			serviceCalled: true,
+		},
+		{
+			name:          "success keeping current session",
+			accessToken:   "valid_access_token",
+			oldPassword:   "old_password",
+			newPassword:   "new_password",
+			keepCurrent:   true,
+			mockAccess:    "new_access_token",
+			mockRefresh:   "new_refresh_token",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			oldPassword:   "old_password",
+			newPassword:   "new_password",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "empty old password",
+			accessToken:   "valid_access_token",
+			oldPassword:   "",
+			newPassword:   "new_password",
+			expectedErr:   "old password is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "empty new password",
+			accessToken:   "valid_access_token",
+			oldPassword:   "old_password",
+			newPassword:   "",
+			expectedErr:   "new password is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "wrong old password",
+			accessToken:   "valid_access_token",
+			oldPassword:   "wrong_password",
+			newPassword:   "new_password",
+			mockError:     fmt.Errorf("service.ChangePassword: %w", changepassword.ErrWrongPassword),
+			expectedErr:   "current password is wrong",
+			serviceCalled: true,
+		},
+		{
+			name:          "locked out",
+			accessToken:   "valid_access_token",
+			oldPassword:   "wrong_password",
+			newPassword:   "new_password",
+			mockError:     fmt.Errorf("service.ChangePassword: %w", &lockout.LockedError{RetryAfter: time.Minute}),
+			expectedErr:   "too many failed attempts, try again later",
+			serviceCalled: true,
+		},
+		{
+			name:          "weak password",
+			accessToken:   "valid_access_token",
+			oldPassword:   "old_password",
+			newPassword:   "short",
+			mockError:     fmt.Errorf("service.ChangePassword: %w", passwordpolicy.ErrWeakPassword),
+			expectedErr:   "password does not meet the policy",
+			serviceCalled: true,
+		},
+		{
+			name:          "revoked token",
+			accessToken:   "old_access_token",
+			oldPassword:   "old_password",
+			newPassword:   "new_password",
+			mockError:     fmt.Errorf("service.ChangePassword: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "valid_access_token",
+			oldPassword:   "old_password",
+			newPassword:   "new_password",
+			mockError:     errors.New("service change password error"),
+			expectedErr:   "failed to change password",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("ChangePassword", mock.Anything, tc.accessToken, tc.oldPassword, tc.newPassword, tc.keepCurrent).
+					Return(tc.mockAccess, tc.mockRefresh, tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.ChangePasswordRequest{
+				AccessToken:        tc.accessToken,
+				OldPassword:        tc.oldPassword,
+				NewPassword:        tc.newPassword,
+				KeepCurrentSession: tc.keepCurrent,
+			}
+
+			resp, err := srv.ChangePassword(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else if tc.keepCurrent {
+				require.NoError(t, err)
+				require.Equal(t, tc.mockAccess, resp.AccessToken)
+				require.Equal(t, tc.mockRefresh, resp.Cookie.Value)
+			} else {
+				require.NoError(t, err)
+				require.Empty(t, resp.AccessToken)
+				require.Nil(t, resp.Cookie)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestEmailChange_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		accessToken   string
+		newEmail      string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			accessToken:   "valid_access_token",
+			newEmail:      "new@example.com",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			newEmail:      "new@example.com",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "empty email",
+			accessToken:   "valid_access_token",
+			newEmail:      "",
+			expectedErr:   "new email is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "invalid email",
+			accessToken:   "valid_access_token",
+			newEmail:      "new@example",
+			mockError:     fmt.Errorf("service.RequestEmailChange: %w", identity.ErrInvalidEmail),
+			expectedErr:   "new email is invalid",
+			serviceCalled: true,
+		},
+		{
+			name:          "same email",
+			accessToken:   "valid_access_token",
+			newEmail:      "test@example.com",
+			mockError:     fmt.Errorf("service.RequestEmailChange: %w", emailchange.ErrSameEmail),
+			expectedErr:   "new email is the current email",
+			serviceCalled: true,
+		},
+		{
+			name:          "email taken",
+			accessToken:   "valid_access_token",
+			newEmail:      "taken@example.com",
+			mockError:     fmt.Errorf("service.RequestEmailChange: %w", domain.ErrEmailTaken),
+			expectedErr:   "email is already taken",
+			serviceCalled: true,
+		},
+		{
+			name:          "revoked token",
+			accessToken:   "old_access_token",
+			newEmail:      "new@example.com",
+			mockError:     fmt.Errorf("service.RequestEmailChange: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "valid_access_token",
+			newEmail:      "new@example.com",
+			mockError:     errors.New("service email change error"),
+			expectedErr:   "failed to request email change",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("RequestEmailChange", mock.Anything, tc.accessToken, tc.newEmail).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.EmailChangeRequest{
+				AccessToken: tc.accessToken,
+				NewEmail:    tc.newEmail,
+			}
+
+			resp, err := srv.RequestEmailChange(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Success)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "RequestEmailChange", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmEmailChange_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			token:         "confirm_token",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty token",
+			token:         "",
+			expectedErr:   "token is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "used or expired token",
+			token:         "used_token",
+			mockError:     fmt.Errorf("service.ConfirmEmailChange: %w", emailchange.ErrInvalidEmailChangeToken),
+			expectedErr:   "confirmation link is invalid or has expired",
+			serviceCalled: true,
+		},
+		{
+			name:          "address registered in the meantime",
+			token:         "confirm_token",
+			mockError:     fmt.Errorf("service.ConfirmEmailChange: %w", domain.ErrEmailTaken),
+			expectedErr:   "email is already taken",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			token:         "confirm_token",
+			mockError:     errors.New("service confirm email error"),
+			expectedErr:   "failed to confirm email change",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("ConfirmEmailChange", mock.Anything, tc.token).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			resp, err := srv.ConfirmEmailChange(context.Background(), &pb.TokenRequest{TokenPod: tc.token})
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Success)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "ConfirmEmailChange", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelEmailChange_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			token:         "cancel_token",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty token",
+			token:         "",
+			expectedErr:   "token is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "no pending change",
+			token:         "stale_token",
+			mockError:     fmt.Errorf("service.CancelEmailChange: %w", emailchange.ErrInvalidEmailChangeToken),
+			expectedErr:   "cancel link is invalid or the change is no longer pending",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			token:         "cancel_token",
+			mockError:     errors.New("service cancel email error"),
+			expectedErr:   "failed to cancel email change",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("CancelEmailChange", mock.Anything, tc.token).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			resp, err := srv.CancelEmailChange(context.Background(), &pb.TokenRequest{TokenPod: tc.token})
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.True(t, resp.Success)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "CancelEmailChange", mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnlockAccount_Unit(t *testing.T) {
+	tests := []struct {
+		name          string
+		accessToken   string
+		login         string
+		ip            string
+		mockError     error
+		expectedErr   string
+		serviceCalled bool
+	}{
+		{
+			name:          "success",
+			accessToken:   "admin_access_token",
+			login:         "test_login",
+			serviceCalled: true,
+		},
+		{
+			name:          "success by ip",
+			accessToken:   "admin_access_token",
+			ip:            "203.0.113.7",
+			serviceCalled: true,
+		},
+		{
+			name:          "empty access token",
+			accessToken:   "",
+			login:         "test_login",
+			expectedErr:   "AssetToken is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "nothing to unlock",
+			accessToken:   "admin_access_token",
+			expectedErr:   "login or ip is required",
+			serviceCalled: false,
+		},
+		{
+			name:          "not an admin",
+			accessToken:   "user_access_token",
+			login:         "test_login",
+			mockError:     fmt.Errorf("service.UnlockAccount: %w", unlock.ErrForbidden),
+			expectedErr:   "admin role required",
+			serviceCalled: true,
+		},
+		{
+			name:          "revoked token",
+			accessToken:   "old_access_token",
+			login:         "test_login",
+			mockError:     fmt.Errorf("service.UnlockAccount: %w", myjwt.ErrTokenRevoked),
+			expectedErr:   "access token is no longer valid",
+			serviceCalled: true,
+		},
+		{
+			name:          "Service error",
+			accessToken:   "admin_access_token",
+			login:         "test_login",
+			mockError:     errors.New("service unlock error"),
+			expectedErr:   "failed to unlock account",
+			serviceCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+
+			if tc.serviceCalled {
+				mockService.On("UnlockAccount", mock.Anything, tc.accessToken, tc.login, tc.ip).
+					Return(tc.mockError).Once()
+			}
+
+			srv := newTestServer(t, mockService)
+
+			req := &pb.UnlockRequest{
+				AccessToken: tc.accessToken,
+				Login:       tc.login,
+				Ip:          tc.ip,
+			}
+
+			resp, err := srv.UnlockAccount(context.Background(), req)
+
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expectedErr)
+				require.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, resp)
+			}
+
+			if !tc.serviceCalled {
+				mockService.AssertNotCalled(t, "UnlockAccount", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRateLimit_Unit(t *testing.T) {
+	log := slogdiscard.NewDiscardLogger()
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Access: time.Hour},
+	}
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+
+	limiter := &ratelimit.Limiter{
+		Store: ratelimit.NewMemory(),
+		Cfg: config.RateLimit{
+			Methods: map[string]config.Budget{
+				"CreateUser":     {Every: time.Minute, Burst: 2},
+				"ChangePassword": {Every: time.Minute, Burst: 1, PerSubject: true},
+			},
+		},
+	}
+	interceptor := gateway.RateLimit(log, limiter, keys)
+
+	fromIP := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+	call := func(ctx context.Context, method string, req any) error {
+		_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/" + method},
+			func(context.Context, any) (any, error) { return &pb.Okey{Success: true}, nil })
+		return err
+	}
+
+	t.Run("burst then rejected with retry info", func(t *testing.T) {
+		require.NoError(t, call(fromIP("10.0.0.1"), "CreateUser", &pb.UserCreateRequest{}))
+		require.NoError(t, call(fromIP("10.0.0.1"), "CreateUser", &pb.UserCreateRequest{}))
+
+		err := call(fromIP("10.0.0.1"), "CreateUser", &pb.UserCreateRequest{})
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.ResourceExhausted, st.Code())
+		require.Len(t, st.Details(), 1)
+
+		retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+		require.True(t, ok)
+		require.Greater(t, retryInfo.GetRetryDelay().AsDuration(), 59*time.Second)
+	})
+
+	t.Run("buckets are per ip", func(t *testing.T) {
+		require.NoError(t, call(fromIP("10.0.0.2"), "CreateUser", &pb.UserCreateRequest{}))
+	})
+
+	t.Run("forged x-forwarded-for does not open a new bucket", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(fromIP("10.0.0.1"), metadata.Pairs("x-forwarded-for", "203.0.113.7"))
+		require.Equal(t, codes.ResourceExhausted, status.Code(call(ctx, "CreateUser", &pb.UserCreateRequest{})))
+	})
+
+	t.Run("unlisted method is not limited", func(t *testing.T) {
+		for range 5 {
+			require.NoError(t, call(fromIP("10.0.0.1"), "GetJWKS", &pb.Empty{}))
+		}
+	})
+
+	t.Run("per subject budget follows the login across ips", func(t *testing.T) {
+		token, err := myjwt.CreateAccessToken(cfg, keys, log, myjwt.Claims{Login: "test_login", SessionID: myjwt.NewSessionID()})
+		require.NoError(t, err)
+
+		require.NoError(t, call(fromIP("10.0.1.1"), "ChangePassword", &pb.ChangePasswordRequest{AccessToken: token}))
+		err = call(fromIP("10.0.1.2"), "ChangePassword", &pb.ChangePasswordRequest{AccessToken: token})
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		require.NoError(t, call(fromIP("10.0.1.3"), "ChangePassword", &pb.ChangePasswordRequest{AccessToken: "invalid"}))
+	})
+
+	// Failing open keeps logins working while the shared store is down;
+	// the lockout still bounds password guessing meanwhile.
+	t.Run("store failure lets the call through", func(t *testing.T) {
+		failing := gateway.RateLimit(log, &ratelimit.Limiter{
+			Store: failingRateLimitStore{},
+			Cfg:   config.RateLimit{Default: config.Budget{Every: time.Minute, Burst: 1}},
+		}, keys)
+
+		for range 3 {
+			resp, err := failing(fromIP("10.0.2.1"), &pb.UserCreateRequest{}, &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/CreateUser"},
+				func(context.Context, any) (any, error) { return &pb.Okey{Success: true}, nil })
+			require.NoError(t, err)
+			require.Equal(t, &pb.Okey{Success: true}, resp)
+		}
+	})
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) TakeToken(context.Context, string, time.Duration, int) (time.Duration, error) {
+	return 0, errors.New("store is down")
+}
+
+func (failingRateLimitStore) PurgeRateLimits(context.Context) (int64, error) {
+	return 0, errors.New("store is down")
+}
+
+func TestDomainErrors_ErrorInfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockError    error
+		expectedCode codes.Code
+		reason       string
+	}{
+		{"user exists", domain.ErrUserExists, codes.AlreadyExists, gateway.ReasonUserExists},
+		{"email taken", domain.ErrEmailTaken, codes.AlreadyExists, gateway.ReasonEmailTaken},
+		{"user not found", domain.ErrUserNotFound, codes.NotFound, gateway.ReasonUserNotFound},
+		{"invalid credentials", domain.ErrInvalidCredentials, codes.Unauthenticated, gateway.ReasonInvalidCredentials},
+		{"token expired", domain.ErrTokenExpired, codes.Unauthenticated, gateway.ReasonTokenExpired},
+		{"token invalid", domain.ErrTokenInvalid, codes.Unauthenticated, gateway.ReasonTokenInvalid},
+		{"token revoked", myjwt.ErrTokenRevoked, codes.Unauthenticated, gateway.ReasonTokenRevoked},
+		{"not verified", domain.ErrNotVerified, codes.FailedPrecondition, gateway.ReasonNotVerified},
+		{"inactive", domain.ErrInactive, codes.PermissionDenied, gateway.ReasonInactive},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := mocks.NewServiceAuth(t)
+			mockService.On("ChangePassword", mock.Anything, "access_token", "old_password", "new_password", false).
+				Return("", "", fmt.Errorf("service.ChangePassword: %w", tc.mockError)).Once()
+
+			srv := newTestServer(t, mockService)
+
+			resp, err := srv.ChangePassword(context.Background(), &pb.ChangePasswordRequest{
+				AccessToken: "access_token",
+				OldPassword: "old_password",
+				NewPassword: "new_password",
+			})
+			require.Nil(t, resp)
+
+			st, ok := status.FromError(err)
+			require.True(t, ok)
+			require.Equal(t, tc.expectedCode, st.Code())
+			require.Len(t, st.Details(), 1)
+
+			info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+			require.True(t, ok)
+			require.Equal(t, tc.reason, info.GetReason())
+			require.Equal(t, gateway.ErrorDomain, info.GetDomain())
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAccessLog_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	chain := []grpc.UnaryServerInterceptor{gateway.AccessLog(log), gateway.Recovery(log)}
+
+	call := func(ctx context.Context, handler grpc.UnaryHandler) error {
+		info := &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/CurrentUser"}
+		next := handler
+		for i := len(chain) - 1; i >= 0; i-- {
+			interceptor, inner := chain[i], next
+			next = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		_, err := next(ctx, &pb.UserCurrentRequest{})
+		return err
+	}
+	records := func(t *testing.T) []map[string]any {
+		t.Helper()
+		var out []map[string]any
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var rec map[string]any
+			require.NoError(t, dec.Decode(&rec))
+			out = append(out, rec)
+		}
+		buf.Reset()
+		return out
+	}
+
+	t.Run("id from metadata reaches the service logger", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-123"))
+		err := call(ctx, func(ctx context.Context, _ any) (any, error) {
+			require.Equal(t, "req-123", gateway.RequestID(ctx))
+			logger.FromContext(ctx, nil).Info("inside service")
+			return nil, status.Error(codes.NotFound, "user not found")
+		})
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		recs := records(t)
+		require.Len(t, recs, 2)
+		require.Equal(t, "inside service", recs[0]["msg"])
+		require.Equal(t, "req-123", recs[0]["request_id"])
+		require.Equal(t, "/auth.Auth/CurrentUser", recs[0]["method"])
+		require.Equal(t, "request finished", recs[1]["msg"])
+		require.Equal(t, "req-123", recs[1]["request_id"])
+		require.Equal(t, "NotFound", recs[1]["code"])
+		require.Contains(t, recs[1], "latency")
+	})
+
+	t.Run("missing or malformed id is replaced", func(t *testing.T) {
+		for _, ctx := range []context.Context{
+			context.Background(),
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "two words")),
+		} {
+			var got string
+			err := call(ctx, func(ctx context.Context, _ any) (any, error) {
+				got = gateway.RequestID(ctx)
+				return &pb.CurrentUserResponse{}, nil
+			})
+			require.NoError(t, err)
+			require.NotEmpty(t, got)
+			require.NotEqual(t, "two words", got)
+
+			recs := records(t)
+			require.Len(t, recs, 1)
+			require.Equal(t, got, recs[0]["request_id"])
+			require.Equal(t, "OK", recs[0]["code"])
+		}
+	})
+
+	t.Run("panic becomes internal with stack trace", func(t *testing.T) {
+		err := call(context.Background(), func(context.Context, any) (any, error) {
+			panic("boom")
+		})
+		require.Equal(t, codes.Internal, status.Code(err))
+
+		recs := records(t)
+		require.Len(t, recs, 2)
+		require.Equal(t, "panic in handler", recs[0]["msg"])
+		require.Equal(t, "boom", recs[0]["panic"])
+		require.Contains(t, recs[0]["stack"], "runtime/debug.Stack")
+		require.Equal(t, recs[0]["request_id"], recs[1]["request_id"])
+		require.Equal(t, "Internal", recs[1]["code"])
+		require.Equal(t, "ERROR", recs[1]["level"])
+	})
+}
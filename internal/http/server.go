@@ -0,0 +1,63 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/service"
+)
+
+type Server struct {
+	Service service.ServiceAuth
+	Log     *slog.Logger
+}
+
+func New(Log *slog.Logger, serv service.ServiceAuth, lis net.Listener) (*http.Server, error) {
+	srv := &Server{Service: serv, Log: Log}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/jwks.json", srv.JWKS)
+
+	return serve(Log, "HTTP", mux, lis), nil
+}
+
+// NewAdmin serves the process counters, auth_password_rehashed_total among
+// them, at /debug/vars. lis must not be reachable by clients.
+func NewAdmin(Log *slog.Logger, lis net.Listener) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	return serve(Log, "Admin HTTP", mux, lis), nil
+}
+
+func serve(Log *slog.Logger, name string, handler http.Handler, lis net.Listener) *http.Server {
+	s := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		Log.Info(name+" server started", slog.String("addr", lis.Addr().String()))
+		if err := s.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Log.Error(name+" server failed", logger.Err(err))
+			os.Exit(1)
+		}
+	}()
+	return s
+}
+
+func (s *Server) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := json.NewEncoder(w).Encode(s.Service.PublicKeys(r.Context())); err != nil {
+		s.Log.Error("failed to write jwks", logger.Err(err))
+	}
+}
@@ -0,0 +1,54 @@
+package account
+
+import (
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+)
+
+// Values of config.Account.Unverified.
+const (
+	UnverifiedDeny       = "deny"
+	UnverifiedRestricted = "restricted"
+	UnverifiedAllow      = "allow"
+)
+
+// ScopeUnverified is the "scope" claim of tokens issued to an unverified
+// account in the restricted mode. Introspection reports it, so resource
+// servers can tell such tokens apart; this service refuses them wherever
+// CheckFull is used.
+const ScopeUnverified = "unverified"
+
+// Check decides whether user may get or keep using tokens. It returns
+// domain.ErrInactive for a deactivated account and domain.ErrNotVerified
+// for an unverified one unless cfg lets it in; any unknown mode counts as
+// "deny". scope is the scope the tokens must carry, empty for full access.
+func Check(cfg config.Account, user *domain.User) (scope string, err error) {
+	if err = user.CheckActive(); err != nil {
+		return "", err
+	}
+	if user.IsVerified {
+		return "", nil
+	}
+
+	switch cfg.Unverified {
+	case UnverifiedAllow:
+		return "", nil
+	case UnverifiedRestricted:
+		return ScopeUnverified, nil
+	}
+	return "", domain.ErrNotVerified
+}
+
+// CheckFull is Check for actions a restricted token may not take: the
+// unverified account gets domain.ErrNotVerified in the restricted mode too.
+// Only reading the account and resending the confirmation stay open to it.
+func CheckFull(cfg config.Account, user *domain.User) error {
+	scope, err := Check(cfg, user)
+	if err != nil {
+		return err
+	}
+	if scope == ScopeUnverified {
+		return domain.ErrNotVerified
+	}
+	return nil
+}
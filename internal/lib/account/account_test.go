@@ -0,0 +1,48 @@
+package account_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+)
+
+func TestCheck(t *testing.T) {
+	verified := domain.User{IsActive: true, IsVerified: true}
+	unverified := domain.User{IsActive: true}
+	inactive := domain.User{IsVerified: true}
+
+	tests := []struct {
+		name      string
+		mode      string
+		user      domain.User
+		wantScope string
+		wantErr   error
+		// wantFullErr is what CheckFull returns.
+		wantFullErr error
+	}{
+		{name: "verified, deny", mode: account.UnverifiedDeny, user: verified},
+		{name: "verified, restricted", mode: account.UnverifiedRestricted, user: verified},
+		{name: "verified, allow", mode: account.UnverifiedAllow, user: verified},
+		{name: "unverified, deny", mode: account.UnverifiedDeny, user: unverified, wantErr: domain.ErrNotVerified, wantFullErr: domain.ErrNotVerified},
+		{name: "unverified, restricted", mode: account.UnverifiedRestricted, user: unverified, wantScope: account.ScopeUnverified, wantFullErr: domain.ErrNotVerified},
+		{name: "unverified, allow", mode: account.UnverifiedAllow, user: unverified},
+		{name: "unverified, unknown mode", mode: "maybe", user: unverified, wantErr: domain.ErrNotVerified, wantFullErr: domain.ErrNotVerified},
+		{name: "inactive, allow", mode: account.UnverifiedAllow, user: inactive, wantErr: domain.ErrInactive, wantFullErr: domain.ErrInactive},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Account{Unverified: tc.mode}
+
+			scope, err := account.Check(cfg, &tc.user)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantScope, scope)
+
+			require.ErrorIs(t, account.CheckFull(cfg, &tc.user), tc.wantFullErr)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package clientinfo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Info describes the device behind a gRPC call.
+type Info struct {
+	UserAgent string
+	IP        string
+}
+
+type infoKey struct{}
+
+// NewContext returns a copy of ctx that carries info for FromContext.
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// FromContext returns the Info a Resolver stored in ctx. Without one, the
+// IP is the transport peer's and x-forwarded-for is ignored, since nothing
+// says which proxy wrote it.
+func FromContext(ctx context.Context) Info {
+	if info, ok := ctx.Value(infoKey{}).(Info); ok {
+		return info
+	}
+	return (&Resolver{}).Resolve(ctx)
+}
+
+// Resolver finds the client of a call behind the proxies it trusts.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver trusts x-forwarded-for hops added by proxies within cidrs.
+func NewResolver(cidrs []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+// Resolve reads the caller's user agent from metadata and its IP from the
+// transport peer. Only when the peer is a trusted proxy is x-forwarded-for
+// walked from the right, past every trusted hop, to the first address no
+// trusted proxy vouches for; hops to its left may be made up by the client.
+func (r *Resolver) Resolve(ctx context.Context) Info {
+	var info Info
+
+	var forwarded []string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			info.UserAgent = ua[0]
+		}
+		for _, xff := range md.Get("x-forwarded-for") {
+			forwarded = append(forwarded, strings.Split(xff, ",")...)
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return info
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	info.IP = host
+
+	addr, ok := parseAddr(host)
+	if !ok {
+		return info
+	}
+	for i := len(forwarded) - 1; i >= 0 && r.trustedAddr(addr); i-- {
+		hop, ok := parseAddr(forwarded[i])
+		if !ok {
+			break
+		}
+		addr = hop
+	}
+	info.IP = addr.String()
+
+	return info
+}
+
+func (r *Resolver) trustedAddr(addr netip.Addr) bool {
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAddr accepts a bare address or one with a port, as some proxies
+// append it.
+func parseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
@@ -0,0 +1,108 @@
+package clientinfo_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	resolver, err := clientinfo.NewResolver([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		peer       string
+		forwarded  []string
+		expectedIP string
+	}{
+		{
+			name:       "direct client",
+			peer:       "198.51.100.1",
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "header from untrusted peer is ignored",
+			peer:       "198.51.100.1",
+			forwarded:  []string{"203.0.113.7"},
+			expectedIP: "198.51.100.1",
+		},
+		{
+			name:       "trusted proxy",
+			peer:       "10.0.0.5",
+			forwarded:  []string{"203.0.113.7"},
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "forged hops left of the client are skipped",
+			peer:       "10.0.0.5",
+			forwarded:  []string{"192.0.2.99, 203.0.113.7"},
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "chain of trusted proxies",
+			peer:       "10.0.0.5",
+			forwarded:  []string{"203.0.113.7, 10.1.1.1", "10.2.2.2"},
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "hop with port",
+			peer:       "10.0.0.5",
+			forwarded:  []string{"203.0.113.7:4711"},
+			expectedIP: "203.0.113.7",
+		},
+		{
+			name:       "garbage hop stops at the last trusted proxy",
+			peer:       "10.0.0.5",
+			forwarded:  []string{"203.0.113.7, not-an-ip"},
+			expectedIP: "10.0.0.5",
+		},
+		{
+			name:       "trusted proxy without header",
+			peer:       "10.0.0.5",
+			expectedIP: "10.0.0.5",
+		},
+		{
+			name:       "ipv6 proxy",
+			peer:       "fd00::1",
+			forwarded:  []string{"2001:db8::7"},
+			expectedIP: "2001:db8::7",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tc.peer), Port: 50000}})
+			md := metadata.MD{"user-agent": {"test-agent"}}
+			if len(tc.forwarded) > 0 {
+				md["x-forwarded-for"] = tc.forwarded
+			}
+			ctx = metadata.NewIncomingContext(ctx, md)
+
+			info := resolver.Resolve(ctx)
+			require.Equal(t, tc.expectedIP, info.IP)
+			require.Equal(t, "test-agent", info.UserAgent)
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 50000}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", "203.0.113.7"))
+
+	require.Equal(t, "198.51.100.1", clientinfo.FromContext(ctx).IP)
+
+	ctx = clientinfo.NewContext(ctx, clientinfo.Info{IP: "192.0.2.1"})
+	require.Equal(t, "192.0.2.1", clientinfo.FromContext(ctx).IP)
+}
+
+func TestNewResolver_InvalidCIDR(t *testing.T) {
+	_, err := clientinfo.NewResolver([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package cooldown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+)
+
+// ErrTooManyRequests is returned by callers when Allow says no.
+var ErrTooManyRequests = errors.New("too many requests")
+
+// Cooldown lets each key act at most once per period. It is a token bucket
+// of one kept in the rate limit store, so with the PostgreSQL backend the
+// cooldown holds across replicas, and full buckets are purged with the rest.
+type Cooldown struct {
+	store  ratelimit.Store
+	name   string
+	period time.Duration
+}
+
+// New returns a cooldown whose keys are stored under name, which must be
+// unique among cooldowns and rate limited methods.
+func New(store ratelimit.Store, name string, period time.Duration) *Cooldown {
+	return &Cooldown{store: store, name: name, period: period}
+}
+
+// Allow reports whether key may act now and, if so, starts its cooldown.
+func (c *Cooldown) Allow(ctx context.Context, key string) (bool, error) {
+	const op = "cooldown.Allow"
+
+	if c.period <= 0 {
+		return true, nil
+	}
+	wait, err := c.store.TakeToken(ctx, "cooldown:"+c.name+"|"+key, c.period, 1)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return wait == 0, nil
+}
@@ -0,0 +1,58 @@
+package cooldown_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/lib/cooldown"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+)
+
+func allow(t *testing.T, c *cooldown.Cooldown, key string) bool {
+	t.Helper()
+	ok, err := c.Allow(context.Background(), key)
+	require.NoError(t, err)
+	return ok
+}
+
+func TestCooldown_Allow(t *testing.T) {
+	store := ratelimit.NewMemory()
+	c := cooldown.New(store, "resend|email", time.Hour)
+
+	require.True(t, allow(t, c, "a@example.com"))
+	require.False(t, allow(t, c, "a@example.com"))
+	require.False(t, allow(t, c, "a@example.com"))
+	require.True(t, allow(t, c, "b@example.com"), "keys cool down separately")
+
+	other := cooldown.New(store, "reset|email", time.Hour)
+	require.True(t, allow(t, other, "a@example.com"), "names do not share keys")
+}
+
+// Two replicas sharing one store share the cooldown.
+func TestCooldown_SharedStore(t *testing.T) {
+	store := ratelimit.NewMemory()
+	replicaA := cooldown.New(store, "resend|email", time.Hour)
+	replicaB := cooldown.New(store, "resend|email", time.Hour)
+
+	require.True(t, allow(t, replicaA, "a@example.com"))
+	require.False(t, allow(t, replicaB, "a@example.com"))
+}
+
+func TestCooldown_Expires(t *testing.T) {
+	c := cooldown.New(ratelimit.NewMemory(), "resend|email", 20*time.Millisecond)
+
+	require.True(t, allow(t, c, "a@example.com"))
+	require.False(t, allow(t, c, "a@example.com"))
+	time.Sleep(30 * time.Millisecond)
+	require.True(t, allow(t, c, "a@example.com"))
+}
+
+func TestCooldown_ZeroPeriod(t *testing.T) {
+	c := cooldown.New(ratelimit.NewMemory(), "resend|email", 0)
+
+	require.True(t, allow(t, c, "a@example.com"))
+	require.True(t, allow(t, c, "a@example.com"))
+}
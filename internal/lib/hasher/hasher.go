@@ -0,0 +1,129 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+)
+
+var (
+	ErrMismatch           = errors.New("password does not match hash")
+	ErrUnknownAlgorithm   = errors.New("unknown password hash algorithm")
+	ErrInvalidHash        = errors.New("invalid password hash format")
+	ErrUnsupportedVersion = errors.New("unsupported password hash version")
+)
+
+// PasswordHasher hashes passwords into a self-describing string
+// and verifies passwords against hashes produced by the same algorithm.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) error
+	Owns(hash string) bool
+	NeedsRehash(hash string) bool
+}
+
+// Hasher hashes with the configured algorithm and verifies with
+// whichever algorithm produced the stored hash.
+type Hasher struct {
+	current    PasswordHasher
+	algorithms []PasswordHasher
+	// dummy is a hash of a random password made with the current
+	// algorithm, verified when there is no stored hash to check.
+	dummy string
+}
+
+func New(cfg *config.Config) (*Hasher, error) {
+	const op = "hasher.New"
+
+	b := &Bcrypt{Cost: cfg.Password.Bcrypt.Cost}
+	a := &Argon2id{
+		Memory:      cfg.Password.Argon2id.Memory,
+		Iterations:  cfg.Password.Argon2id.Iterations,
+		Parallelism: cfg.Password.Argon2id.Parallelism,
+		SaltLength:  cfg.Password.Argon2id.SaltLength,
+		KeyLength:   cfg.Password.Argon2id.KeyLength,
+	}
+	s := &Scrypt{
+		N:          cfg.Password.Scrypt.N,
+		R:          cfg.Password.Scrypt.R,
+		P:          cfg.Password.Scrypt.P,
+		SaltLength: cfg.Password.Scrypt.SaltLength,
+		KeyLength:  cfg.Password.Scrypt.KeyLength,
+	}
+
+	h := &Hasher{algorithms: []PasswordHasher{b, a, s}}
+	switch strings.ToLower(cfg.Password.Algorithm) {
+	case "bcrypt":
+		h.current = b
+	case "argon2id":
+		h.current = a
+	case "scrypt":
+		if s.N < 2 || s.N&(s.N-1) != 0 {
+			return nil, fmt.Errorf("%s: scrypt N must be a power of two greater than 1", op)
+		}
+		h.current = s
+	default:
+		return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownAlgorithm, cfg.Password.Algorithm)
+	}
+
+	password, err := randomSalt(32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if h.dummy, err = h.current.Hash(string(password)); err != nil {
+		return nil, fmt.Errorf("%s: failed to prepare dummy hash: %w", op, err)
+	}
+
+	return h, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	const op = "hasher.Hash"
+
+	hash, err := h.current.Hash(password)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return hash, nil
+}
+
+func (h *Hasher) Verify(hash, password string) error {
+	const op = "hasher.Verify"
+
+	for _, alg := range h.algorithms {
+		if alg.Owns(hash) {
+			if err := alg.Verify(hash, password); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", op, ErrUnknownAlgorithm)
+}
+
+// VerifyDummy takes as long as a failed Verify with the current algorithm.
+// It is called when the account is not found, so the response time does
+// not tell whether it exists.
+func (h *Hasher) VerifyDummy(password string) {
+	_ = h.current.Verify(h.dummy, password)
+}
+
+// NeedsRehash reports whether hash was produced by another algorithm
+// or with parameters that differ from the current configuration.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if !h.current.Owns(hash) {
+		return true
+	}
+	return h.current.NeedsRehash(hash)
+}
+
+func randomSalt(n uint32) ([]byte, error) {
+	salt := make([]byte, n)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
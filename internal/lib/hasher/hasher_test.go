@@ -0,0 +1,128 @@
+package hasher_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+)
+
+func testConfig(algorithm string) *config.Config {
+	return &config.Config{Password: config.Password{
+		Algorithm: algorithm,
+		Bcrypt:    config.Bcrypt{Cost: 4},
+		Argon2id:  config.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		Scrypt:    config.Scrypt{N: 16, R: 1, P: 1, SaltLength: 16, KeyLength: 32},
+	}}
+}
+
+func newHasher(t *testing.T, cfg *config.Config) *hasher.Hasher {
+	t.Helper()
+	h, err := hasher.New(cfg)
+	require.NoError(t, err)
+	return h
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		prefix    string
+		wantErr   bool
+	}{
+		{name: "bcrypt", algorithm: "bcrypt", prefix: "$2a$"},
+		{name: "argon2id", algorithm: "argon2id", prefix: "$argon2id$"},
+		{name: "scrypt", algorithm: "scrypt", prefix: "$scrypt$"},
+		{name: "case insensitive", algorithm: "Argon2id", prefix: "$argon2id$"},
+		{name: "unknown", algorithm: "md5", wantErr: true},
+		{name: "empty", algorithm: "", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := hasher.New(testConfig(tc.algorithm))
+			if tc.wantErr {
+				require.ErrorIs(t, err, hasher.ErrUnknownAlgorithm)
+				return
+			}
+			require.NoError(t, err)
+
+			hash, err := h.Hash("password123")
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(hash, tc.prefix), hash)
+			require.NoError(t, h.Verify(hash, "password123"))
+			require.ErrorIs(t, h.Verify(hash, "password124"), hasher.ErrMismatch)
+		})
+	}
+
+	t.Run("scrypt N not a power of two", func(t *testing.T) {
+		cfg := testConfig("scrypt")
+		cfg.Password.Scrypt.N = 1000
+		_, err := hasher.New(cfg)
+		require.Error(t, err)
+	})
+}
+
+func TestVerify_AnyKnownAlgorithm(t *testing.T) {
+	bcryptHash, err := newHasher(t, testConfig("bcrypt")).Hash("password123")
+	require.NoError(t, err)
+
+	h := newHasher(t, testConfig("argon2id"))
+	require.NoError(t, h.Verify(bcryptHash, "password123"))
+	require.ErrorIs(t, h.Verify(bcryptHash, "password124"), hasher.ErrMismatch)
+	require.ErrorIs(t, h.Verify("plaintext", "plaintext"), hasher.ErrUnknownAlgorithm)
+	require.ErrorIs(t, h.Verify("$argon2id$v=19$broken", "password123"), hasher.ErrInvalidHash)
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hashWith := func(cfg *config.Config) string {
+		hash, err := newHasher(t, cfg).Hash("password123")
+		require.NoError(t, err)
+		return hash
+	}
+
+	bcryptCost5 := testConfig("bcrypt")
+	bcryptCost5.Password.Bcrypt.Cost = 5
+	argon2Memory := testConfig("argon2id")
+	argon2Memory.Password.Argon2id.Memory = 128
+	argon2Key := testConfig("argon2id")
+	argon2Key.Password.Argon2id.KeyLength = 16
+	scryptN := testConfig("scrypt")
+	scryptN.Password.Scrypt.N = 32
+
+	tests := []struct {
+		name    string
+		current *config.Config
+		hash    string
+		want    bool
+	}{
+		{name: "bcrypt same cost", current: testConfig("bcrypt"), hash: hashWith(testConfig("bcrypt")), want: false},
+		{name: "bcrypt other cost", current: bcryptCost5, hash: hashWith(testConfig("bcrypt")), want: true},
+		{name: "argon2id same params", current: testConfig("argon2id"), hash: hashWith(testConfig("argon2id")), want: false},
+		{name: "argon2id other memory", current: argon2Memory, hash: hashWith(testConfig("argon2id")), want: true},
+		{name: "argon2id other key length", current: argon2Key, hash: hashWith(testConfig("argon2id")), want: true},
+		{name: "scrypt same params", current: testConfig("scrypt"), hash: hashWith(testConfig("scrypt")), want: false},
+		{name: "scrypt other N", current: scryptN, hash: hashWith(testConfig("scrypt")), want: true},
+		{name: "bcrypt hash under argon2id", current: testConfig("argon2id"), hash: hashWith(testConfig("bcrypt")), want: true},
+		{name: "argon2id hash under bcrypt", current: testConfig("bcrypt"), hash: hashWith(testConfig("argon2id")), want: true},
+		{name: "broken current hash", current: testConfig("argon2id"), hash: "$argon2id$v=19$broken", want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, newHasher(t, tc.current).NeedsRehash(tc.hash))
+		})
+	}
+}
+
+func TestVerifyDummy(t *testing.T) {
+	for _, algorithm := range []string{"bcrypt", "argon2id", "scrypt"} {
+		t.Run(algorithm, func(t *testing.T) {
+			h := newHasher(t, testConfig(algorithm))
+			require.NotPanics(t, func() { h.VerifyDummy("password123") })
+		})
+	}
+}
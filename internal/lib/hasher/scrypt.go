@@ -0,0 +1,109 @@
+package hasher
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const scryptPrefix = "$scrypt$"
+
+// Scrypt produces PHC-formatted hashes:
+// $scrypt$ln=<log2(N)>,r=<r>,p=<p>$<salt>$<key>
+type Scrypt struct {
+	N          int
+	R          int
+	P          int
+	SaltLength int
+	KeyLength  int
+}
+
+type scryptParams struct {
+	n    int
+	r    int
+	p    int
+	salt []byte
+	key  []byte
+}
+
+func (s *Scrypt) Hash(password string) (string, error) {
+	salt, err := randomSalt(uint32(s.SaltLength))
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(password), salt, s.N, s.R, s.P, s.KeyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%sln=%d,r=%d,p=%d$%s$%s",
+		scryptPrefix,
+		bits.TrailingZeros(uint(s.N)), s.R, s.P,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (s *Scrypt) Verify(hash, password string) error {
+	p, err := decodeScrypt(hash)
+	if err != nil {
+		return err
+	}
+
+	key, err := scrypt.Key([]byte(password), p.salt, p.n, p.r, p.p, len(p.key))
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (s *Scrypt) Owns(hash string) bool {
+	return strings.HasPrefix(hash, scryptPrefix)
+}
+
+func (s *Scrypt) NeedsRehash(hash string) bool {
+	p, err := decodeScrypt(hash)
+	if err != nil {
+		return true
+	}
+	return p.n != s.N ||
+		p.r != s.R ||
+		p.p != s.P ||
+		len(p.salt) != s.SaltLength ||
+		len(p.key) != s.KeyLength
+}
+
+func decodeScrypt(hash string) (*scryptParams, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 {
+		return nil, ErrInvalidHash
+	}
+
+	var ln int
+	var p scryptParams
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &p.r, &p.p); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if ln <= 0 || ln >= 63 {
+		return nil, ErrInvalidHash
+	}
+	p.n = 1 << ln
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrInvalidHash
+	}
+
+	return &p, nil
+}
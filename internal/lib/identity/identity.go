@@ -0,0 +1,121 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrInvalidLogin = errors.New("invalid login")
+	ErrInvalidEmail = errors.New("invalid email")
+)
+
+// Rules normalizes and validates logins and email addresses. Values are
+// stored exactly as returned, so two inputs that differ only in case or
+// Unicode representation name the same account.
+type Rules struct {
+	LoginMinLength int
+	LoginMaxLength int
+	LoginCharset   *regexp.Regexp
+	EmailMaxLength int
+}
+
+func New(cfg *config.Config) (*Rules, error) {
+	const op = "identity.New"
+
+	v := cfg.Validation
+	charset, err := regexp.Compile(v.Login.Charset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: login charset: %w", op, err)
+	}
+	return &Rules{
+		LoginMinLength: v.Login.MinLength,
+		LoginMaxLength: v.Login.MaxLength,
+		LoginCharset:   charset,
+		EmailMaxLength: v.Email.MaxLength,
+	}, nil
+}
+
+// NormalizeLogin folds raw the way Login does without checking it against
+// the rules. Lookups use it, so accounts created under older or laxer rules
+// can still be found.
+func NormalizeLogin(raw string) string {
+	return fold(raw)
+}
+
+// NormalizeEmail folds raw the way Email does without checking it against
+// the rules. A domain without an ASCII form is only folded.
+func NormalizeEmail(raw string) string {
+	email, err := normalizeEmail(raw)
+	if err != nil {
+		return fold(raw)
+	}
+	return email
+}
+
+// Login returns the normalized login or an error wrapping ErrInvalidLogin.
+func (r *Rules) Login(raw string) (string, error) {
+	login := NormalizeLogin(raw)
+
+	n := utf8.RuneCountInString(login)
+	if n < r.LoginMinLength {
+		return "", fmt.Errorf("%w: shorter than %d characters", ErrInvalidLogin, r.LoginMinLength)
+	}
+	if n > r.LoginMaxLength {
+		return "", fmt.Errorf("%w: longer than %d characters", ErrInvalidLogin, r.LoginMaxLength)
+	}
+	if !r.LoginCharset.MatchString(login) {
+		return "", fmt.Errorf("%w: characters outside %s", ErrInvalidLogin, r.LoginCharset)
+	}
+	return login, nil
+}
+
+// Email returns the normalized address or an error wrapping ErrInvalidEmail.
+// The domain is stored in its ASCII (punycode) form, so an internationalized
+// domain and its xn-- spelling are the same address.
+func (r *Rules) Email(raw string) (string, error) {
+	email, err := normalizeEmail(raw)
+	if err != nil {
+		return "", err
+	}
+	if domain := email[strings.LastIndexByte(email, '@')+1:]; !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("%w: domain has no dot", ErrInvalidEmail)
+	}
+
+	if len(email) > r.EmailMaxLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidEmail, r.EmailMaxLength)
+	}
+	// A bare addr-spec parses back to itself; display names, comments
+	// and quoted local parts do not and are rejected.
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("%w: not an RFC 5322 address", ErrInvalidEmail)
+	}
+	return email, nil
+}
+
+func normalizeEmail(raw string) (string, error) {
+	email := fold(raw)
+
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 {
+		return "", fmt.Errorf("%w: expected local-part@domain", ErrInvalidEmail)
+	}
+	domain, err := idna.Lookup.ToASCII(email[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("%w: domain: %v", ErrInvalidEmail, err)
+	}
+	return email[:at] + "@" + domain, nil
+}
+
+func fold(s string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(s)))
+}
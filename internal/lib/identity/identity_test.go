@@ -0,0 +1,118 @@
+package identity_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+)
+
+func newRules(t *testing.T) *identity.Rules {
+	t.Helper()
+
+	rules, err := identity.New(&config.Config{Validation: config.Validation{
+		Login: config.LoginRules{MinLength: 3, MaxLength: 32, Charset: "^[a-z0-9][a-z0-9._-]*$"},
+		Email: config.EmailRules{MaxLength: 254},
+	}})
+	require.NoError(t, err)
+	return rules
+}
+
+func TestRules_Login(t *testing.T) {
+	rules := newRules(t)
+
+	tests := []struct {
+		name        string
+		raw         string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "plain", raw: "test_user", expected: "test_user"},
+		{name: "case and spaces folded", raw: "  Test.User ", expected: "test.user"},
+		{name: "fullwidth folded by NFKC", raw: "ｔｅｓｔ１", expected: "test1"},
+		{name: "shortest", raw: "abc", expected: "abc"},
+		{name: "longest", raw: strings.Repeat("a", 32), expected: strings.Repeat("a", 32)},
+		{name: "too short", raw: "ab", expectedErr: true},
+		{name: "too long", raw: strings.Repeat("a", 33), expectedErr: true},
+		{name: "outside charset", raw: "test user", expectedErr: true},
+		{name: "leading punctuation", raw: "_test", expectedErr: true},
+		{name: "non latin", raw: "тест", expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			login, err := rules.Login(tc.raw)
+			if tc.expectedErr {
+				require.ErrorIs(t, err, identity.ErrInvalidLogin)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, login)
+		})
+	}
+}
+
+func TestRules_Email(t *testing.T) {
+	rules := newRules(t)
+
+	tests := []struct {
+		name        string
+		raw         string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "plain", raw: "test@example.com", expected: "test@example.com"},
+		{name: "case folded", raw: " Test@Example.COM ", expected: "test@example.com"},
+		{name: "idn domain to punycode", raw: "test@bücher.example", expected: "test@xn--bcher-kva.example"},
+		{name: "punycode kept", raw: "test@xn--bcher-kva.example", expected: "test@xn--bcher-kva.example"},
+		{name: "no at", raw: "test.example.com", expectedErr: true},
+		{name: "empty local part", raw: "@example.com", expectedErr: true},
+		{name: "empty domain", raw: "test@", expectedErr: true},
+		{name: "domain without dot", raw: "test@localhost", expectedErr: true},
+		{name: "display name", raw: "Test <test@example.com>", expectedErr: true},
+		{name: "too long", raw: strings.Repeat("a", 250) + "@example.com", expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			email, err := rules.Email(tc.raw)
+			if tc.expectedErr {
+				require.ErrorIs(t, err, identity.ErrInvalidEmail)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, email)
+		})
+	}
+}
+
+func TestNormalize_MatchesRules(t *testing.T) {
+	rules := newRules(t)
+
+	for _, raw := range []string{"Test.User", "ｔｅｓｔ１"} {
+		login, err := rules.Login(raw)
+		require.NoError(t, err)
+		require.Equal(t, login, identity.NormalizeLogin(raw))
+	}
+	for _, raw := range []string{"Test@Example.COM", "test@bücher.example"} {
+		email, err := rules.Email(raw)
+		require.NoError(t, err)
+		require.Equal(t, email, identity.NormalizeEmail(raw))
+	}
+}
+
+func TestNormalize_DoesNotValidate(t *testing.T) {
+	require.Equal(t, "x!", identity.NormalizeLogin(" X! "))
+	require.Equal(t, "root@localhost", identity.NormalizeEmail("Root@LOCALHOST"))
+	require.Equal(t, "not an email", identity.NormalizeEmail("Not an Email"))
+}
+
+func TestNew_InvalidCharset(t *testing.T) {
+	_, err := identity.New(&config.Config{Validation: config.Validation{
+		Login: config.LoginRules{Charset: "["},
+	}})
+	require.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package myjwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// JWK is the public part of a signing key as described in RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verify our tokens: the active key first,
+// then retired keys that have not expired. HMAC secrets are never published.
+func (k *Keys) JWKS() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	if jwk, ok := toJWK(k.active); ok {
+		set.Keys = append(set.Keys, jwk)
+	}
+	for _, r := range k.retired {
+		if jwk, ok := toJWK(r); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func toJWK(k *key) (JWK, bool) {
+	jwk, err := publicJWK(k.verify)
+	if err != nil {
+		return JWK{}, false
+	}
+	jwk.Kid = k.id
+	jwk.Use = "sig"
+	jwk.Alg = k.method.Alg()
+	return jwk, true
+}
+
+func publicJWK(pub any) (JWK, error) {
+	enc := base64.RawURLEncoding
+
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   enc.EncodeToString(p.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(p.E)).Bytes()),
+		}, nil
+
+	case *ecdsa.PublicKey:
+		ecdhKey, err := p.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// Uncompressed point: 0x04 || X || Y.
+		point := ecdhKey.Bytes()[1:]
+		size := len(point) / 2
+		return JWK{
+			Kty: "EC",
+			Crv: p.Curve.Params().Name,
+			X:   enc.EncodeToString(point[:size]),
+			Y:   enc.EncodeToString(point[size:]),
+		}, nil
+
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(p),
+		}, nil
+	}
+
+	return JWK{}, errors.New("key has no public JWK form")
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint used as kid.
+func thumbprint(pub any) (string, error) {
+	jwk, err := publicJWK(pub)
+	if err != nil {
+		return "", err
+	}
+
+	// Required members only, in lexicographic order.
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -1,23 +1,38 @@
 package myjwt
 
 import (
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func CreateEmailJWT(cfg *config.Config, log *slog.Logger, email string) (string, error) {
-	const op = "jwt.CreateEmailJWT"
+// TokenUse is stored in the "token_use" claim and tells what a token may be used for.
+type TokenUse string
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["email"] = email
-	claims["exp"] = time.Now().Add(time.Minute * 30).Unix()
-	tokenString, err := token.SignedString([]byte(cfg.JWT.Secret))
+const (
+	TokenAccess       TokenUse = "access"
+	TokenRefresh      TokenUse = "refresh"
+	TokenVerification TokenUse = "verification"
+)
+
+const claimTokenUse = "token_use"
+
+var (
+	ErrWrongTokenUse = errors.New("wrong token use")
+	ErrTokenRevoked  = errors.New("token revoked")
+)
+
+func CreateAccessToken(cfg *config.Config, keys *Keys, log *slog.Logger, claims Claims) (string, error) {
+	const op = "jwt.CreateAccessToken"
+
+	tokenString, err := createToken(keys, TokenAccess, cfg.TokenTTL.Access, claims.mapClaims())
 	if err != nil {
 		log.Error("failed to sign jwt token", logger.Err(err))
 		return "", fmt.Errorf("%s: %w", op, err)
@@ -25,14 +40,12 @@ func CreateEmailJWT(cfg *config.Config, log *slog.Logger, email string) (string,
 	return tokenString, nil
 }
 
-func CreateLoginJWT(cfg *config.Config, log *slog.Logger, login string) (string, error) {
-	const op = "jwt.CreateLoginJWT"
+// CreateRefreshToken issues a refresh token bound to a session.
+// Every rotation keeps the session, so a replayed old token can revoke it.
+func CreateRefreshToken(cfg *config.Config, keys *Keys, log *slog.Logger, claims Claims) (string, error) {
+	const op = "jwt.CreateRefreshToken"
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["login"] = login
-	claims["exp"] = time.Now().Add(time.Minute * 30).Unix()
-	tokenString, err := token.SignedString([]byte(cfg.JWT.Secret))
+	tokenString, err := createToken(keys, TokenRefresh, cfg.TokenTTL.Refresh, claims.mapClaims())
 	if err != nil {
 		log.Error("failed to sign jwt token", logger.Err(err))
 		return "", fmt.Errorf("%s: %w", op, err)
@@ -40,48 +53,182 @@ func CreateLoginJWT(cfg *config.Config, log *slog.Logger, login string) (string,
 	return tokenString, nil
 }
 
-func GetEmail(tokenString string, secret string) (string, error) {
-	const op = "jwt.GetEmail"
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
+func CreateVerificationToken(cfg *config.Config, keys *Keys, log *slog.Logger, email string) (string, error) {
+	const op = "jwt.CreateVerificationToken"
 
+	tokenString, err := createToken(keys, TokenVerification, cfg.TokenTTL.Verification, jwt.MapClaims{"email": email})
 	if err != nil {
+		log.Error("failed to sign jwt token", logger.Err(err))
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
+	return tokenString, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if email, ok := claims["email"].(string); ok {
-			return email, nil
-		}
+// GetVerificationClaims returns the email and id of a verification token.
+func GetVerificationClaims(tokenString string, keys *Keys) (*Claims, error) {
+	const op = "jwt.GetVerificationClaims"
+
+	claims, err := parseToken(tokenString, keys, TokenVerification)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrTokenInvalid)
+	}
+
+	result := registered(claims)
+	result.Email = email
+	return result, nil
+}
+
+// Claims are the subject and session of an access or refresh token.
+// Generation is the user's token generation when the token was issued.
+// Scope, when set, limits what the token may be used for.
+// ID, Use, IssuedAt and ExpiresAt describe the token itself.
+type Claims struct {
+	Login      string
+	Email      string
+	SessionID  string
+	Generation int64
+	Scope      string
+	ID         string
+	Use        TokenUse
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+}
+
+func (c Claims) mapClaims() jwt.MapClaims {
+	claims := jwt.MapClaims{"login": c.Login, "sid": c.SessionID, "gen": c.Generation}
+	if c.Scope != "" {
+		claims["scope"] = c.Scope
+	}
+	return claims
+}
+
+// CheckGeneration rejects tokens issued before the user's token generation
+// was bumped, e.g. by logging out everywhere.
+func (c *Claims) CheckGeneration(current int64) error {
+	if c.Generation < current {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func GetClaims(tokenString string, keys *Keys, use TokenUse) (*Claims, error) {
+	const op = "jwt.GetClaims"
+
+	claims, err := parseToken(tokenString, keys, use)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessionClaims(op, claims)
+}
+
+// Introspect returns the claims of an access or refresh token,
+// whichever it is. Verification tokens are rejected.
+func Introspect(tokenString string, keys *Keys) (*Claims, error) {
+	const op = "jwt.Introspect"
+
+	claims, err := parseToken(tokenString, keys, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch use, _ := claims[claimTokenUse].(string); TokenUse(use) {
+	case TokenAccess, TokenRefresh:
+		return sessionClaims(op, claims)
+	}
+	return nil, fmt.Errorf("%s: %w: %w", op, domain.ErrTokenInvalid, ErrWrongTokenUse)
+}
+
+func sessionClaims(op string, claims jwt.MapClaims) (*Claims, error) {
+	login, ok := claims["login"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrTokenInvalid)
+	}
+	sessionID, ok := claims["sid"].(string)
+	if !ok || sessionID == "" {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrTokenInvalid)
+	}
+	// Without an id a token could not be revoked before it expires.
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, fmt.Errorf("%s: %w", op, domain.ErrTokenInvalid)
 	}
 
-	return "", fmt.Errorf("%s: invalid token", op)
+	// Tokens issued before generations existed carry no "gen" and count as 0.
+	generation, _ := claims["gen"].(float64)
+
+	result := registered(claims)
+	result.Login = login
+	result.SessionID = sessionID
+	result.Generation = int64(generation)
+	result.Scope, _ = claims["scope"].(string)
+	return result, nil
+}
+
+func registered(claims jwt.MapClaims) *Claims {
+	var result Claims
+	result.ID, _ = claims["jti"].(string)
+	use, _ := claims[claimTokenUse].(string)
+	result.Use = TokenUse(use)
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		result.IssuedAt = iat.Time
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		result.ExpiresAt = exp.Time
+	}
+	return &result
+}
+
+// NewSessionID returns a random identifier for a new session.
+func NewSessionID() string {
+	return rand.Text()
 }
 
-func GetLogin(tokenString string, secret string) (string, error) {
-	const op = "jwt.GetLogin"
+func createToken(keys *Keys, use TokenUse, ttl time.Duration, claims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	claims[claimTokenUse] = string(use)
+	claims["jti"] = rand.Text()
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	k := keys.signingKey()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.id
+	return token.SignedString(k.sign)
+}
 
+func parseToken(tokenString string, keys *Keys, use TokenUse) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		k, err := keys.verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != k.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(secret), nil
-	})
-
+		return k.verify, nil
+	}, jwt.WithValidMethods(keys.algorithms()))
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("%w: %w", domain.ErrTokenExpired, err)
+		}
+		return nil, fmt.Errorf("%w: %w", domain.ErrTokenInvalid, err)
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if login, ok := claims["login"].(string); ok {
-			return login, nil
-		}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, domain.ErrTokenInvalid
+	}
+
+	// An empty use leaves the check to the caller.
+	if got, _ := claims[claimTokenUse].(string); use != "" && got != string(use) {
+		return nil, fmt.Errorf("%w: %w: expected %q, got %q", domain.ErrTokenInvalid, ErrWrongTokenUse, use, got)
 	}
 
-	return "", fmt.Errorf("%s: invalid token", op)
+	return claims, nil
 }
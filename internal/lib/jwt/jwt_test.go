@@ -0,0 +1,92 @@
+package myjwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+)
+
+func TestGetClaims_TokenUse(t *testing.T) {
+	cfg := testConfig("HS256", "")
+	keys, err := LoadKeys(cfg)
+	require.NoError(t, err)
+	log := slogdiscard.NewDiscardLogger()
+
+	claims := Claims{Login: "test_user", SessionID: "session", Generation: 1}
+	access, err := CreateAccessToken(cfg, keys, log, claims)
+	require.NoError(t, err)
+	refresh, err := CreateRefreshToken(cfg, keys, log, claims)
+	require.NoError(t, err)
+	verification, err := CreateVerificationToken(cfg, keys, log, "test@example.com")
+	require.NoError(t, err)
+
+	tokens := map[TokenUse]string{
+		TokenAccess:       access,
+		TokenRefresh:      refresh,
+		TokenVerification: verification,
+	}
+
+	for issued, token := range tokens {
+		for _, expected := range []TokenUse{TokenAccess, TokenRefresh} {
+			t.Run(string(issued)+" as "+string(expected), func(t *testing.T) {
+				got, err := GetClaims(token, keys, expected)
+				if issued != expected {
+					require.ErrorIs(t, err, ErrWrongTokenUse)
+					require.ErrorIs(t, err, domain.ErrTokenInvalid)
+					return
+				}
+				require.NoError(t, err)
+				require.Equal(t, expected, got.Use)
+				require.Equal(t, "test_user", got.Login)
+				require.Equal(t, "session", got.SessionID)
+				require.Equal(t, int64(1), got.Generation)
+			})
+		}
+
+		t.Run(string(issued)+" as verification", func(t *testing.T) {
+			got, err := GetVerificationClaims(token, keys)
+			if issued != TokenVerification {
+				require.ErrorIs(t, err, ErrWrongTokenUse)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "test@example.com", got.Email)
+		})
+
+		t.Run(string(issued)+" introspected", func(t *testing.T) {
+			got, err := Introspect(token, keys)
+			if issued == TokenVerification {
+				require.ErrorIs(t, err, ErrWrongTokenUse)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, issued, got.Use)
+		})
+	}
+}
+
+func TestGetClaims_TokenTTL(t *testing.T) {
+	cfg := testConfig("HS256", "")
+	cfg.TokenTTL.Access = 15 * time.Minute
+	cfg.TokenTTL.Refresh = 48 * time.Hour
+	keys, err := LoadKeys(cfg)
+	require.NoError(t, err)
+	log := slogdiscard.NewDiscardLogger()
+
+	access, err := CreateAccessToken(cfg, keys, log, Claims{Login: "test_user", SessionID: "session"})
+	require.NoError(t, err)
+	refresh, err := CreateRefreshToken(cfg, keys, log, Claims{Login: "test_user", SessionID: "session"})
+	require.NoError(t, err)
+
+	accessClaims, err := GetClaims(access, keys, TokenAccess)
+	require.NoError(t, err)
+	require.Equal(t, cfg.TokenTTL.Access, accessClaims.ExpiresAt.Sub(accessClaims.IssuedAt))
+
+	refreshClaims, err := GetClaims(refresh, keys, TokenRefresh)
+	require.NoError(t, err)
+	require.Equal(t, cfg.TokenTTL.Refresh, refreshClaims.ExpiresAt.Sub(refreshClaims.IssuedAt))
+}
@@ -0,0 +1,202 @@
+package myjwt
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("unknown signing key")
+)
+
+type key struct {
+	id     string
+	method jwt.SigningMethod
+	sign   any
+	verify any
+	// expiresAt is set when a key is rotated out; zero means it never expires.
+	expiresAt time.Time
+}
+
+// Keys is a key ring with one active signing key and retired keys
+// that are only used to verify tokens issued before a rotation.
+// Verification accepts only the algorithm of the key named by "kid",
+// whatever the token header says.
+type Keys struct {
+	mu      sync.RWMutex
+	active  *key
+	retired map[string]*key
+}
+
+func LoadKeys(cfg *config.Config) (*Keys, error) {
+	const op = "jwt.LoadKeys"
+
+	k := &Keys{retired: make(map[string]*key)}
+	if err := k.Reload(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return k, nil
+}
+
+// Reload loads the keys named in cfg. If the active key changed, the previous
+// one keeps verifying tokens until the longest token TTL has passed.
+func (k *Keys) Reload(cfg *config.Config) error {
+	const op = "jwt.Reload"
+
+	active, err := loadKey(cfg.JWT.Algorithm, cfg.JWT.Secret, cfg.JWT.PrivateKeyPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	retired := make(map[string]*key)
+	for _, rk := range cfg.JWT.RetiredKeys {
+		r, err := loadKey(rk.Algorithm, "", rk.PrivateKeyPath)
+		if err != nil {
+			return fmt.Errorf("%s: retired key %s: %w", op, rk.PrivateKeyPath, err)
+		}
+		retired[r.id] = r
+	}
+
+	now := time.Now()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for id, r := range k.retired {
+		if _, ok := retired[id]; !ok && !r.expiresAt.IsZero() && now.Before(r.expiresAt) {
+			retired[id] = r
+		}
+	}
+	if k.active != nil && k.active.id != active.id {
+		prev := *k.active
+		prev.expiresAt = now.Add(maxTTL(cfg))
+		retired[prev.id] = &prev
+	}
+	delete(retired, active.id)
+
+	k.active = active
+	k.retired = retired
+	return nil
+}
+
+// ActiveKeyID returns the kid stamped on newly issued tokens.
+func (k *Keys) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active.id
+}
+
+func (k *Keys) signingKey() *key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+func (k *Keys) verificationKey(kid string) (*key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if kid == "" || kid == k.active.id {
+		return k.active, nil
+	}
+	if r, ok := k.retired[kid]; ok && (r.expiresAt.IsZero() || time.Now().Before(r.expiresAt)) {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+func (k *Keys) algorithms() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	algs := []string{k.active.method.Alg()}
+	for _, r := range k.retired {
+		algs = append(algs, r.method.Alg())
+	}
+	return algs
+}
+
+func loadKey(alg, secret, path string) (*key, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if secret == "" {
+			return nil, fmt.Errorf("SECRET_JWT is required for %s", method.Alg())
+		}
+		sum := sha256.Sum256([]byte("kid:" + secret))
+		return &key{
+			id:     "hs-" + hex.EncodeToString(sum[:8]),
+			method: method,
+			sign:   []byte(secret),
+			verify: []byte(secret),
+		}, nil
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("private key path is required for %s", method.Alg())
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := parsePrivateKey(method, pem)
+	if err != nil {
+		return nil, err
+	}
+	if k.id, err = thumbprint(k.verify); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func parsePrivateKey(method jwt.SigningMethod, pem []byte) (*key, error) {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		k, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		return &key{method: method, sign: k, verify: &k.PublicKey}, nil
+
+	case *jwt.SigningMethodECDSA:
+		k, err := jwt.ParseECPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		if k.Curve.Params().BitSize != m.CurveBits {
+			return nil, fmt.Errorf("%s requires a %d-bit curve, got %s", m.Alg(), m.CurveBits, k.Curve.Params().Name)
+		}
+		return &key{method: method, sign: k, verify: &k.PublicKey}, nil
+
+	case *jwt.SigningMethodEd25519:
+		k, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := k.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("key is not an Ed25519 private key")
+		}
+		return &key{method: method, sign: edKey, verify: edKey.Public()}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, method.Alg())
+}
+
+func maxTTL(cfg *config.Config) time.Duration {
+	return max(cfg.TokenTTL.Access, cfg.TokenTTL.Refresh, cfg.TokenTTL.Verification)
+}
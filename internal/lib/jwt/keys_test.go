@@ -0,0 +1,252 @@
+package myjwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+)
+
+const testSecret = "test-secret"
+
+func writeKey(t *testing.T, priv any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return path
+}
+
+func newECKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return priv, writeKey(t, priv)
+}
+
+func testConfig(alg, path string) *config.Config {
+	return &config.Config{
+		JWT:      config.JWT{Algorithm: alg, Secret: testSecret, PrivateKeyPath: path},
+		TokenTTL: config.TokenTTL{Access: time.Hour, Refresh: time.Hour, Verification: time.Hour},
+	}
+}
+
+// newRing returns an HS256 ring that still verifies tokens of the ES256
+// key it was rotated from.
+func newRing(t *testing.T) (*Keys, *ecdsa.PrivateKey, string) {
+	t.Helper()
+	ecPriv, ecPath := newECKey(t)
+	keys, err := LoadKeys(testConfig("ES256", ecPath))
+	require.NoError(t, err)
+	ecID := keys.ActiveKeyID()
+	require.NoError(t, keys.Reload(testConfig("HS256", "")))
+	return keys, ecPriv, ecID
+}
+
+func signed(t *testing.T, method jwt.SigningMethod, kid string, signKey any) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		claimTokenUse: string(TokenAccess),
+		"login":       "test_user",
+		"sid":         "session",
+		"iat":         now.Unix(),
+		"exp":         now.Add(time.Hour).Unix(),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(signKey)
+	require.NoError(t, err)
+	return s
+}
+
+func TestParseToken(t *testing.T) {
+	keys, ecPriv, ecID := newRing(t)
+	hsID := keys.ActiveKeyID()
+	otherPriv, _ := newECKey(t)
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr error
+	}{
+		{
+			name:  "active key",
+			token: func(t *testing.T) string { return signed(t, jwt.SigningMethodHS256, hsID, []byte(testSecret)) },
+		},
+		{
+			name:  "active key without kid",
+			token: func(t *testing.T) string { return signed(t, jwt.SigningMethodHS256, "", []byte(testSecret)) },
+		},
+		{
+			name:  "retired key",
+			token: func(t *testing.T) string { return signed(t, jwt.SigningMethodES256, ecID, ecPriv) },
+		},
+		{
+			name:    "HMAC alg with the kid of an EC key",
+			token:   func(t *testing.T) string { return signed(t, jwt.SigningMethodHS256, ecID, []byte(testSecret)) },
+			wantErr: domain.ErrTokenInvalid,
+		},
+		{
+			name:    "EC alg with the kid of the HMAC key",
+			token:   func(t *testing.T) string { return signed(t, jwt.SigningMethodES256, hsID, ecPriv) },
+			wantErr: domain.ErrTokenInvalid,
+		},
+		{
+			name: "alg none",
+			token: func(t *testing.T) string {
+				return signed(t, jwt.SigningMethodNone, hsID, jwt.UnsafeAllowNoneSignatureType)
+			},
+			wantErr: domain.ErrTokenInvalid,
+		},
+		{
+			name:    "unknown kid",
+			token:   func(t *testing.T) string { return signed(t, jwt.SigningMethodES256, "unknown", otherPriv) },
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name:    "unknown key under a known kid",
+			token:   func(t *testing.T) string { return signed(t, jwt.SigningMethodES256, ecID, otherPriv) },
+			wantErr: domain.ErrTokenInvalid,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := parseToken(tc.token(t), keys, TokenAccess)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				require.ErrorIs(t, err, domain.ErrTokenInvalid)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "test_user", claims["login"])
+		})
+	}
+}
+
+func TestVerificationKey_RetiredKeyExpires(t *testing.T) {
+	keys, ecPriv, ecID := newRing(t)
+	token := signed(t, jwt.SigningMethodES256, ecID, ecPriv)
+
+	k, err := keys.verificationKey(ecID)
+	require.NoError(t, err)
+	require.Equal(t, "ES256", k.method.Alg())
+	require.WithinDuration(t, time.Now().Add(time.Hour), k.expiresAt, time.Minute)
+	_, err = parseToken(token, keys, TokenAccess)
+	require.NoError(t, err)
+
+	keys.retired[ecID].expiresAt = time.Now().Add(-time.Second)
+
+	_, err = keys.verificationKey(ecID)
+	require.ErrorIs(t, err, ErrUnknownKey)
+	_, err = parseToken(token, keys, TokenAccess)
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestVerificationKey_ExpiredKeyDroppedOnReload(t *testing.T) {
+	keys, _, ecID := newRing(t)
+	keys.retired[ecID].expiresAt = time.Now().Add(-time.Second)
+
+	require.NoError(t, keys.Reload(testConfig("HS256", "")))
+
+	require.NotContains(t, keys.retired, ecID)
+	require.NotContains(t, keys.algorithms(), "ES256")
+}
+
+func TestJWKS(t *testing.T) {
+	keys, ecPriv, ecID := newRing(t)
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cfg := testConfig("HS256", "")
+	cfg.JWT.RetiredKeys = []config.RetiredKey{
+		{Algorithm: "RS256", PrivateKeyPath: writeKey(t, rsaPriv)},
+		{Algorithm: "EdDSA", PrivateKeyPath: writeKey(t, edPriv)},
+	}
+	require.NoError(t, keys.Reload(cfg))
+
+	set := keys.JWKS()
+	require.Len(t, set.Keys, 3, "the HMAC secret is never published")
+
+	out, err := json.Marshal(set)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), `"d"`)
+	require.NotContains(t, string(out), `"k"`)
+	require.NotContains(t, string(out), base64.RawURLEncoding.EncodeToString([]byte(testSecret)))
+
+	byKty := make(map[string]JWK)
+	for _, jwk := range set.Keys {
+		require.Equal(t, "sig", jwk.Use)
+		byKty[jwk.Kty] = jwk
+	}
+
+	ec := byKty["EC"]
+	require.Equal(t, ecID, ec.Kid)
+	require.Equal(t, "ES256", ec.Alg)
+	require.Equal(t, "P-256", ec.Crv)
+	wantID, err := thumbprint(&ecPriv.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, wantID, ec.Kid)
+
+	rs := byKty["RSA"]
+	require.Equal(t, "RS256", rs.Alg)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(rsaPriv.N.Bytes()), rs.N)
+	require.Equal(t, "AQAB", rs.E)
+
+	ed := byKty["OKP"]
+	require.Equal(t, "EdDSA", ed.Alg)
+	require.Equal(t, base64.RawURLEncoding.EncodeToString(edPriv.Public().(ed25519.PublicKey)), ed.X)
+}
+
+// The example key of RFC 7638, section 3.1.
+func TestThumbprint_RFC7638(t *testing.T) {
+	n, err := base64.RawURLEncoding.DecodeString(strings.Join([]string{
+		"0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECP",
+		"ebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY",
+		"368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0f",
+		"M4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}, ""))
+	require.NoError(t, err)
+
+	got, err := thumbprint(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537})
+	require.NoError(t, err)
+	require.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", got)
+}
+
+// A token with neither kid nor jti verifies against the active key, but
+// could not be revoked, so it is refused.
+func TestGetClaims_RequiresID(t *testing.T) {
+	keys, err := LoadKeys(testConfig("HS256", ""))
+	require.NoError(t, err)
+
+	token := signed(t, jwt.SigningMethodHS256, "", []byte(testSecret))
+	_, err = parseToken(token, keys, TokenAccess)
+	require.NoError(t, err)
+
+	_, err = GetClaims(token, keys, TokenAccess)
+	require.ErrorIs(t, err, domain.ErrTokenInvalid)
+	_, err = Introspect(token, keys)
+	require.ErrorIs(t, err, domain.ErrTokenInvalid)
+}
@@ -0,0 +1,172 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrLocked = errors.New("too many failed attempts")
+
+// LockedError carries how long the caller has to wait. It matches ErrLocked.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrLocked, e.RetryAfter)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Store keeps failure counters and locks by key.
+type Store interface {
+	LoginAttemptsForUpdate(ctx context.Context, key string) (*domain.LoginAttempts, error)
+	SaveLoginAttempts(ctx context.Context, attempts *domain.LoginAttempts) error
+	ClearLoginFailures(ctx context.Context, keys []string) error
+}
+
+// Guard tracks failed sign-ins per login and per client IP. Counters live
+// in the Store, so restarting the service does not reset them.
+type Guard struct {
+	Store      Store
+	TxProvider storage.TxProvider
+	Cfg        config.Lockout
+}
+
+func loginKey(login string) string { return "login:" + login }
+func ipKey(ip string) string       { return "ip:" + ip }
+
+// Attempt counts an attempt for login and ip before the password is
+// checked, and returns a *LockedError without counting it if either is
+// locked. The counters are read and written under a row lock, so parallel
+// guesses cannot all slip in under the threshold: the attempt that reaches
+// it locks the key for every later one. Succeed takes a successful attempt
+// back.
+func (g *Guard) Attempt(ctx context.Context, login, ip string) error {
+	const op = "lockout.Attempt"
+
+	err := g.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		keys := g.keys(login, ip)
+		counters := make([]*domain.LoginAttempts, 0, len(keys))
+		for _, key := range keys {
+			attempts, err := g.Store.LoginAttemptsForUpdate(ctx, key)
+			if err != nil {
+				return err
+			}
+			if wait := time.Until(attempts.LockedUntil); wait > 0 {
+				return &LockedError{RetryAfter: wait.Round(time.Second)}
+			}
+			counters = append(counters, attempts)
+		}
+
+		now := time.Now()
+		for _, attempts := range counters {
+			if now.Sub(attempts.LastFailureAt) > g.Cfg.Window {
+				attempts.Failures = 0
+			}
+			attempts.Failures++
+			attempts.LastFailureAt = now
+			attempts.LockedUntil = time.Time{}
+			if delay := g.delay(attempts); delay > 0 {
+				attempts.LockedUntil = now.Add(delay)
+			}
+			if err := g.Store.SaveLoginAttempts(ctx, attempts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Succeed forgets the failures of login. Of the IP counter only the
+// attempt that succeeded is taken back, along with the lock it set, so
+// signing in to one's own account does not reset guessing at others.
+func (g *Guard) Succeed(ctx context.Context, login, ip string) error {
+	const op = "lockout.Succeed"
+
+	if err := g.Store.ClearLoginFailures(ctx, []string{loginKey(login)}); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if ip == "" {
+		return nil
+	}
+
+	err := g.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		attempts, err := g.Store.LoginAttemptsForUpdate(ctx, ipKey(ip))
+		if err != nil {
+			return err
+		}
+		attempts.Failures = max(attempts.Failures-1, 0)
+		attempts.LockedUntil = time.Time{}
+		if delay := g.delay(attempts); delay > 0 {
+			attempts.LockedUntil = attempts.LastFailureAt.Add(delay)
+		}
+		return g.Store.SaveLoginAttempts(ctx, attempts)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Unlock lifts the lock and clears the counters of login and, if given, ip.
+func (g *Guard) Unlock(ctx context.Context, login, ip string) error {
+	const op = "lockout.Unlock"
+
+	if err := g.Store.ClearLoginFailures(ctx, g.keys(login, ip)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// delay is how long attempts locks its key after its latest failure.
+func (g *Guard) delay(attempts *domain.LoginAttempts) time.Duration {
+	threshold := g.Cfg.LoginThreshold
+	if strings.HasPrefix(attempts.Key, ipKey("")) {
+		threshold = g.Cfg.IPThreshold
+	}
+	return Delay(attempts.Failures, threshold, g.Cfg.BaseDelay, g.Cfg.MaxDelay)
+}
+
+func (g *Guard) keys(login, ip string) []string {
+	keys := make([]string, 0, 2)
+	if login != "" {
+		keys = append(keys, loginKey(login))
+	}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+// Delay is how long a key stays locked after its failures-th failure:
+// nothing below threshold, then base doubled per further failure, capped
+// at maxDelay.
+func Delay(failures, threshold int, base, maxDelay time.Duration) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	delay := base
+	for i := threshold; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
@@ -0,0 +1,202 @@
+package lockout_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeStore serializes transactions like the row lock of the real store.
+type fakeStore struct {
+	mu       sync.Mutex
+	attempts map[string]domain.LoginAttempts
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{attempts: make(map[string]domain.LoginAttempts)}
+}
+
+func (s *fakeStore) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(nil)
+}
+
+func (s *fakeStore) LoginAttemptsForUpdate(_ context.Context, key string) (*domain.LoginAttempts, error) {
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = domain.LoginAttempts{Key: key, LastFailureAt: time.Now()}
+	}
+	return &attempts, nil
+}
+
+func (s *fakeStore) SaveLoginAttempts(_ context.Context, attempts *domain.LoginAttempts) error {
+	s.attempts[attempts.Key] = *attempts
+	return nil
+}
+
+func (s *fakeStore) ClearLoginFailures(_ context.Context, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.attempts, key)
+	}
+	return nil
+}
+
+// unlockAll lets the next attempt through while keeping the counters, as
+// if every lock had run out.
+func (s *fakeStore) unlockAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, attempts := range s.attempts {
+		attempts.LockedUntil = time.Time{}
+		s.attempts[key] = attempts
+	}
+}
+
+func newGuard() (*lockout.Guard, *fakeStore) {
+	store := newFakeStore()
+	return &lockout.Guard{
+		Store:      store,
+		TxProvider: store,
+		Cfg: config.Lockout{
+			LoginThreshold: 3,
+			IPThreshold:    5,
+			BaseDelay:      time.Minute,
+			MaxDelay:       10 * time.Minute,
+			Window:         time.Hour,
+		},
+	}, store
+}
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 0, expected: 0},
+		{failures: 2, expected: 0},
+		{failures: 3, expected: time.Minute},
+		{failures: 4, expected: 2 * time.Minute},
+		{failures: 5, expected: 4 * time.Minute},
+		{failures: 6, expected: 8 * time.Minute},
+		{failures: 7, expected: 10 * time.Minute},
+		{failures: 1000, expected: 10 * time.Minute},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.expected, lockout.Delay(tc.failures, 3, time.Minute, 10*time.Minute), "failures=%d", tc.failures)
+	}
+
+	require.Zero(t, lockout.Delay(100, 0, time.Minute, 10*time.Minute), "no threshold never locks")
+}
+
+func TestGuard_Threshold(t *testing.T) {
+	ctx := context.Background()
+	guard, _ := newGuard()
+
+	for range 3 {
+		require.NoError(t, guard.Attempt(ctx, "test_user", "10.0.0.1"))
+	}
+
+	err := guard.Attempt(ctx, "test_user", "10.0.0.2")
+	require.ErrorIs(t, err, lockout.ErrLocked)
+	var lockedErr *lockout.LockedError
+	require.ErrorAs(t, err, &lockedErr)
+	require.InDelta(t, time.Minute.Seconds(), lockedErr.RetryAfter.Seconds(), 1)
+
+	require.NoError(t, guard.Attempt(ctx, "other_user", "10.0.0.1"), "ip is below its own threshold")
+}
+
+func TestGuard_DelayGrows(t *testing.T) {
+	ctx := context.Background()
+	guard, store := newGuard()
+
+	for range 3 {
+		require.NoError(t, guard.Attempt(ctx, "test_user", ""))
+	}
+	for _, expected := range []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute} {
+		store.unlockAll()
+		require.NoError(t, guard.Attempt(ctx, "test_user", ""))
+
+		var lockedErr *lockout.LockedError
+		require.ErrorAs(t, guard.Attempt(ctx, "test_user", ""), &lockedErr)
+		require.InDelta(t, expected.Seconds(), lockedErr.RetryAfter.Seconds(), 1)
+	}
+}
+
+func TestGuard_ParallelAttemptsStopAtThreshold(t *testing.T) {
+	ctx := context.Background()
+	guard, _ := newGuard()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var allowed int
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if guard.Attempt(ctx, "test_user", "") == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 3, allowed)
+}
+
+func TestGuard_LockedAttemptIsNotCounted(t *testing.T) {
+	ctx := context.Background()
+	guard, store := newGuard()
+
+	for range 3 {
+		require.NoError(t, guard.Attempt(ctx, "test_user", "10.0.0.1"))
+	}
+	for range 10 {
+		require.ErrorIs(t, guard.Attempt(ctx, "test_user", "10.0.0.1"), lockout.ErrLocked)
+	}
+
+	require.Equal(t, 3, store.attempts["login:test_user"].Failures)
+	require.Equal(t, 3, store.attempts["ip:10.0.0.1"].Failures)
+}
+
+func TestGuard_Succeed(t *testing.T) {
+	ctx := context.Background()
+	guard, store := newGuard()
+
+	for range 2 {
+		require.NoError(t, guard.Attempt(ctx, "test_user", "10.0.0.1"))
+	}
+	require.NoError(t, guard.Attempt(ctx, "test_user", "10.0.0.1"))
+	require.NoError(t, guard.Succeed(ctx, "test_user", "10.0.0.1"))
+
+	require.NotContains(t, store.attempts, "login:test_user")
+	require.Equal(t, 2, store.attempts["ip:10.0.0.1"].Failures, "only the successful attempt is taken back")
+	require.NoError(t, guard.Attempt(ctx, "test_user", "10.0.0.1"))
+}
+
+func TestGuard_WindowResets(t *testing.T) {
+	ctx := context.Background()
+	guard, store := newGuard()
+
+	for range 2 {
+		require.NoError(t, guard.Attempt(ctx, "test_user", ""))
+	}
+	attempts := store.attempts["login:test_user"]
+	attempts.LastFailureAt = time.Now().Add(-2 * time.Hour)
+	store.attempts["login:test_user"] = attempts
+
+	require.NoError(t, guard.Attempt(ctx, "test_user", ""))
+	require.Equal(t, 1, store.attempts["login:test_user"].Failures)
+}
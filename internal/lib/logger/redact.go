@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Redacted replaces every value that must not be written.
+const Redacted = "[REDACTED]"
+
+var (
+	// A JWT header is base64url JSON, so it always starts with eyJ ("{").
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	emailPattern = regexp.MustCompile(`([^\s@"'<>(),;:]+)@([^\s@"'<>(),;:]+\.[^\s@"'<>(),;:]+)`)
+)
+
+// Redact hides JWTs in s and masks email addresses down to their first
+// character and domain, as in b***@example.com.
+func Redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, Redacted)
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		local, domain, _ := strings.Cut(email, "@")
+		first := []rune(local)[0]
+		return string(first) + "***@" + domain
+	})
+}
+
+// RedactHandler passes records on to another handler with secrets removed.
+// Values of the configured keys are replaced entirely; every other string,
+// including the message and errors, goes through Redact. Structs, maps and
+// slices are logged in their JSON form, so the same applies to their fields.
+type RedactHandler struct {
+	next slog.Handler
+	keys []string
+}
+
+// NewRedactHandler wraps next. A key matches an attribute whose key ends
+// with it, compared case-insensitively and ignoring anything but letters
+// and digits, so "token" also covers "AssetToken" and "refresh_token".
+func NewRedactHandler(next slog.Handler, keys []string) *RedactHandler {
+	h := &RedactHandler{next: next}
+	for _, k := range keys {
+		if k = normalizeKey(k); k != "" {
+			h.keys = append(h.keys, k)
+		}
+	}
+	return h
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, h.redactAttr(a))
+	}
+	return &RedactHandler{next: h.next.WithAttrs(redacted), keys: h.keys}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{next: h.next.WithGroup(name), keys: h.keys}
+}
+
+func (h *RedactHandler) redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() != slog.KindGroup && h.secretKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, 0, len(group))
+		for _, ga := range group {
+			redacted = append(redacted, h.redactAttr(ga))
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+		return slog.Any(a.Key, h.redactAny(a.Value.Any()))
+	}
+	return a
+}
+
+// redactAny walks the JSON form of v. A value without one is dropped, since
+// there is no telling what it holds.
+func (h *RedactHandler) redactAny(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Redacted
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic any
+	if err = dec.Decode(&generic); err != nil {
+		return Redacted
+	}
+	return h.redactJSON(generic)
+}
+
+func (h *RedactHandler) redactJSON(v any) any {
+	switch v := v.(type) {
+	case string:
+		return Redact(v)
+	case []any:
+		for i := range v {
+			v[i] = h.redactJSON(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			if h.secretKey(k) {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = h.redactJSON(v[k])
+		}
+	}
+	return v
+}
+
+func (h *RedactHandler) secretKey(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range h.keys {
+		if strings.HasSuffix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, key)
+}
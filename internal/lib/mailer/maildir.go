@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Maildir writes each message into Dir in maildir layout, so local
+// development can read mail with any maildir-aware client or just cat it.
+type Maildir struct {
+	Dir  string
+	From string
+}
+
+func (m *Maildir) Send(_ context.Context, msg Message) error {
+	const op = "mailer.Maildir.Send"
+
+	raw, err := encode(m.From, msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err = os.MkdirAll(filepath.Join(m.Dir, sub), 0o755); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// Write to tmp and rename into new, so readers never see partial files.
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "." + rand.Text() + ".eml"
+	tmp := filepath.Join(m.Dir, "tmp", name)
+	if err = os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = os.Rename(tmp, filepath.Join(m.Dir, "new", name)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+)
+
+var ErrUnknownBackend = errors.New("unknown mail backend")
+
+// Message is one email with a plain text and an HTML alternative.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the backend named in cfg.
+func New(cfg *config.Config) (Mailer, error) {
+	const op = "mailer.New"
+
+	switch cfg.Mail.Backend {
+	case "smtp":
+		return &SMTP{
+			Host:           cfg.Mail.SMTP.Host,
+			Port:           cfg.Mail.SMTP.Port,
+			Username:       cfg.Mail.SMTP.Username,
+			Password:       cfg.Mail.SMTP.Password,
+			From:           cfg.Mail.From,
+			AllowPlaintext: cfg.Mail.SMTP.AllowPlaintext,
+		}, nil
+	case "file":
+		return &Maildir{Dir: cfg.Mail.Dir, From: cfg.Mail.From}, nil
+	case "memory":
+		return &Memory{}, nil
+	}
+	return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownBackend, cfg.Mail.Backend)
+}
+
+// encode renders msg as a multipart/alternative RFC 5322 message.
+func encode(from string, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err = qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err = qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
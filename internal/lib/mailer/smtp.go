@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+var ErrNoTLS = errors.New("smtp server does not offer STARTTLS")
+
+// SMTP delivers through a relay over STARTTLS. A server without it is
+// refused unless AllowPlaintext is set.
+type SMTP struct {
+	Host           string
+	Port           int
+	Username       string
+	Password       string
+	From           string
+	AllowPlaintext bool
+}
+
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	const op = "mailer.SMTP.Send"
+
+	raw, err := encode(s.From, msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	} else if !s.AllowPlaintext {
+		return fmt.Errorf("%s: %w", op, ErrNoTLS)
+	}
+	if s.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = c.Mail(s.From); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err = w.Write(raw); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,110 @@
+package mailer_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+)
+
+// plaintextServer accepts one SMTP session without offering STARTTLS and
+// sends what it received as DATA to the returned channel.
+func plaintextServer(t *testing.T) (port int, data <-chan string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 8BITMIME")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var body strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				out <- body.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return lis.Addr().(*net.TCPAddr).Port, out
+}
+
+func TestSMTP_RequiresTLS(t *testing.T) {
+	port, data := plaintextServer(t)
+	m := &mailer.SMTP{Host: "127.0.0.1", Port: port, From: "no-reply@example.com"}
+
+	err := m.Send(context.Background(), mailer.Message{To: "user@example.com", Subject: "hi", Text: "secret link"})
+
+	require.ErrorIs(t, err, mailer.ErrNoTLS)
+	require.Empty(t, data, "nothing is sent over plaintext")
+}
+
+func TestSMTP_AllowPlaintext(t *testing.T) {
+	port, data := plaintextServer(t)
+	m := &mailer.SMTP{Host: "127.0.0.1", Port: port, From: "no-reply@example.com", AllowPlaintext: true}
+
+	err := m.Send(context.Background(), mailer.Message{To: "user@example.com", Subject: "hi", Text: "hello"})
+	require.NoError(t, err)
+
+	select {
+	case body := <-data:
+		require.Contains(t, body, "To: user@example.com")
+		require.Contains(t, body, "hello")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message delivered")
+	}
+}
+
+func TestNew_SMTPPlaintextIsOptIn(t *testing.T) {
+	cfg := &config.Config{Mail: config.Mail{Backend: "smtp", SMTP: config.SMTP{Host: "mail.example.com", Port: 587}}}
+
+	m, err := mailer.New(cfg)
+	require.NoError(t, err)
+	require.False(t, m.(*mailer.SMTP).AllowPlaintext)
+
+	cfg.Mail.SMTP.AllowPlaintext = true
+	m, err = mailer.New(cfg)
+	require.NoError(t, err)
+	require.True(t, m.(*mailer.SMTP).AllowPlaintext)
+}
@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+// Verification builds the confirmation email sent after registration.
+func Verification(to, login, link string) (Message, error) {
+	return render("verification", "Confirm your email", to, map[string]string{
+		"Login": login,
+		"Link":  link,
+	})
+}
+
+// PasswordReset builds the email with a single-use password reset link.
+func PasswordReset(to, login, link string) (Message, error) {
+	return render("password_reset", "Reset your password", to, map[string]string{
+		"Login": login,
+		"Link":  link,
+	})
+}
+
+// EmailChange builds the email that confirms a new address.
+func EmailChange(to, login, link string) (Message, error) {
+	return render("email_change", "Confirm your new email", to, map[string]string{
+		"Login": login,
+		"Link":  link,
+	})
+}
+
+// EmailChangeNotice warns the current address about a requested change
+// and carries the link that cancels it.
+func EmailChangeNotice(to, login, newEmail, link string) (Message, error) {
+	return render("email_change_notice", "Your email address is being changed", to, map[string]string{
+		"Login":    login,
+		"NewEmail": newEmail,
+		"Link":     link,
+	})
+}
+
+// render fills templates/<name>.txt and templates/<name>.html with data.
+func render(name, subject, to string, data any) (Message, error) {
+	const op = "mailer.render"
+
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Link returns baseURL/path?token=<token>.
+func Link(baseURL, path, token string) (string, error) {
+	const op = "mailer.Link"
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	u = u.JoinPath(path)
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
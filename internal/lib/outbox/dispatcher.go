@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+)
+
+// Handler delivers one message payload. Returning an error schedules a retry.
+type Handler func(ctx context.Context, payload []byte) error
+
+type Store interface {
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxMessage, error)
+	CompleteOutbox(ctx context.Context, id int64) error
+	FailOutbox(ctx context.Context, msg *domain.OutboxMessage) error
+}
+
+// Dispatcher polls the outbox and hands due messages to the handler for their kind.
+// Failed deliveries back off exponentially; after MaxAttempts a message is dead.
+type Dispatcher struct {
+	Store    Store
+	Handlers map[string]Handler
+	Cfg      config.Outbox
+	Log      *slog.Logger
+}
+
+// Run dispatches until ctx is done. A delivery in flight is allowed to finish,
+// so stopping never leaves a message half-handled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatch(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	work := context.WithoutCancel(ctx)
+
+	messages, err := d.Store.ClaimOutbox(work, d.Cfg.BatchSize, d.Cfg.Lease)
+	if err != nil {
+		d.Log.Error("failed to claim outbox messages", logger.Err(err))
+		return
+	}
+
+	for i := range messages {
+		msg := &messages[i]
+		if ctx.Err() != nil {
+			// Unhandled claims are picked up again once their lease runs out.
+			return
+		}
+		d.deliver(work, msg)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg *domain.OutboxMessage) {
+	log := d.Log.With(slog.Int64("outbox_id", msg.Id), slog.String("kind", msg.Kind), slog.Int("attempt", msg.Attempts))
+
+	handler, ok := d.Handlers[msg.Kind]
+	if !ok {
+		d.fail(ctx, log, msg, fmt.Errorf("no handler for kind %q", msg.Kind), true)
+		return
+	}
+
+	hctx, cancel := context.WithTimeout(ctx, d.Cfg.HandlerTimeout)
+	err := handler(hctx, msg.Payload)
+	cancel()
+	if err != nil {
+		d.fail(ctx, log, msg, err, msg.Attempts >= d.Cfg.MaxAttempts)
+		return
+	}
+
+	if err = d.Store.CompleteOutbox(ctx, msg.Id); err != nil {
+		log.Error("failed to complete outbox message", logger.Err(err))
+		return
+	}
+	log.Debug("outbox message delivered")
+}
+
+func (d *Dispatcher) fail(ctx context.Context, log *slog.Logger, msg *domain.OutboxMessage, cause error, dead bool) {
+	msg.LastError = cause.Error()
+	if dead {
+		msg.Status = domain.OutboxDead
+		msg.NextAttemptAt = time.Now()
+		log.Error("outbox message moved to dead letter", logger.Err(cause))
+	} else {
+		msg.Status = domain.OutboxPending
+		msg.NextAttemptAt = time.Now().Add(d.backoff(msg.Attempts))
+		log.Warn("outbox delivery failed, will retry", logger.Err(cause), slog.Time("next_attempt_at", msg.NextAttemptAt))
+	}
+
+	if err := d.Store.FailOutbox(ctx, msg); err != nil {
+		log.Error("failed to record outbox failure", logger.Err(err))
+	}
+}
+
+// backoff returns BaseBackoff * 2^(attempt-1), capped at MaxBackoff.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.Cfg.BaseBackoff
+	for i := 1; i < attempt && delay < d.Cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.Cfg.MaxBackoff)
+}
@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+)
+
+// fakeStore claims every pending message, whatever its next attempt time,
+// and counts the attempt as ClaimOutboxOp does.
+type fakeStore struct {
+	messages  map[int64]*domain.OutboxMessage
+	completed []int64
+	failures  []domain.OutboxMessage
+}
+
+func newFakeStore(messages ...domain.OutboxMessage) *fakeStore {
+	s := &fakeStore{messages: make(map[int64]*domain.OutboxMessage)}
+	for _, msg := range messages {
+		msg.Status = domain.OutboxPending
+		s.messages[msg.Id] = &msg
+	}
+	return s
+}
+
+func (s *fakeStore) ClaimOutbox(_ context.Context, limit int, _ time.Duration) ([]domain.OutboxMessage, error) {
+	var claimed []domain.OutboxMessage
+	for _, msg := range s.messages {
+		if msg.Status == domain.OutboxPending && len(claimed) < limit {
+			msg.Attempts++
+			claimed = append(claimed, *msg)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) CompleteOutbox(_ context.Context, id int64) error {
+	s.completed = append(s.completed, id)
+	delete(s.messages, id)
+	return nil
+}
+
+func (s *fakeStore) FailOutbox(_ context.Context, msg *domain.OutboxMessage) error {
+	s.failures = append(s.failures, *msg)
+	s.messages[msg.Id] = msg
+	return nil
+}
+
+func testConfig() config.Outbox {
+	return config.Outbox{
+		BatchSize:      10,
+		HandlerTimeout: time.Second,
+		MaxAttempts:    4,
+		BaseBackoff:    time.Second,
+		MaxBackoff:     5 * time.Second,
+	}
+}
+
+func newDispatcher(store Store, handlers map[string]Handler) *Dispatcher {
+	return &Dispatcher{Store: store, Handlers: handlers, Cfg: testConfig(), Log: slogdiscard.NewDiscardLogger()}
+}
+
+func TestDispatch_Delivered(t *testing.T) {
+	store := newFakeStore(domain.OutboxMessage{Id: 1, Kind: "email", Payload: []byte("hello")})
+	var got []byte
+	d := newDispatcher(store, map[string]Handler{
+		"email": func(_ context.Context, payload []byte) error { got = payload; return nil },
+	})
+
+	d.dispatch(context.Background())
+
+	require.Equal(t, []byte("hello"), got)
+	require.Equal(t, []int64{1}, store.completed)
+	require.Empty(t, store.failures)
+}
+
+func TestDispatch_RetriesThenDeadLetter(t *testing.T) {
+	store := newFakeStore(domain.OutboxMessage{Id: 1, Kind: "email"})
+	calls := 0
+	d := newDispatcher(store, map[string]Handler{
+		"email": func(context.Context, []byte) error { calls++; return errors.New("smtp unavailable") },
+	})
+
+	for range d.Cfg.MaxAttempts + 2 {
+		d.dispatch(context.Background())
+	}
+
+	require.Equal(t, d.Cfg.MaxAttempts, calls, "a dead message is not handled again")
+	require.Len(t, store.failures, d.Cfg.MaxAttempts)
+	require.Empty(t, store.completed)
+
+	for i, failure := range store.failures[:len(store.failures)-1] {
+		require.Equal(t, i+1, failure.Attempts)
+		require.Equal(t, domain.OutboxPending, failure.Status)
+		require.Equal(t, "smtp unavailable", failure.LastError)
+	}
+	dead := store.failures[len(store.failures)-1]
+	require.Equal(t, d.Cfg.MaxAttempts, dead.Attempts)
+	require.Equal(t, domain.OutboxDead, dead.Status)
+	require.Equal(t, "smtp unavailable", dead.LastError)
+}
+
+func TestDispatch_BackoffGrows(t *testing.T) {
+	store := newFakeStore(domain.OutboxMessage{Id: 1, Kind: "email"})
+	d := newDispatcher(store, map[string]Handler{
+		"email": func(context.Context, []byte) error { return errors.New("smtp unavailable") },
+	})
+
+	var delays []time.Duration
+	for range d.Cfg.MaxAttempts - 1 {
+		start := time.Now()
+		d.dispatch(context.Background())
+		delays = append(delays, store.messages[1].NextAttemptAt.Sub(start))
+	}
+
+	for i := 1; i < len(delays); i++ {
+		require.Greater(t, delays[i], delays[i-1])
+	}
+	require.InDelta(t, float64(time.Second), float64(delays[0]), float64(100*time.Millisecond))
+}
+
+func TestDispatch_UnknownKindIsDead(t *testing.T) {
+	store := newFakeStore(domain.OutboxMessage{Id: 1, Kind: "fax"})
+	d := newDispatcher(store, map[string]Handler{})
+
+	d.dispatch(context.Background())
+
+	require.Len(t, store.failures, 1)
+	require.Equal(t, domain.OutboxDead, store.failures[0].Status)
+	require.Contains(t, store.failures[0].LastError, `"fax"`)
+}
+
+func TestBackoff(t *testing.T) {
+	d := newDispatcher(newFakeStore(), nil)
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 5 * time.Second},
+		{attempt: 100, want: 5 * time.Second},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.want, d.backoff(tc.attempt), "attempt %d", tc.attempt)
+	}
+}
@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// KindWebhook messages carry a WebhookEvent for a single endpoint,
+// so one failing endpoint does not resend the event to the others.
+const KindWebhook = "webhook"
+
+type WebhookEvent struct {
+	URL   string `json:"url"`
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// Webhook POSTs {"event": ..., "data": ...} to the event URL.
+// Any non-2xx answer is treated as a failed delivery.
+func Webhook(client *http.Client) Handler {
+	return func(ctx context.Context, payload []byte) error {
+		const op = "outbox.Webhook"
+
+		var event WebhookEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		body, err := json.Marshal(struct {
+			Event string `json:"event"`
+			Data  any    `json:"data"`
+		}{event.Event, event.Data})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, event.URL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s: %s answered %s", op, event.URL, resp.Status)
+		}
+		return nil
+	}
+}
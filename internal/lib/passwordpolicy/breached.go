@@ -0,0 +1,69 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is the number of leading hex digits that pick a range file.
+const prefixLength = 5
+
+// Breached looks passwords up in a directory of k-anonymity range files,
+// the layout of the Pwned Passwords range API: <PREFIX>.txt holds the last
+// 35 hex digits of every leaked SHA-1 starting with the 5-digit PREFIX, one
+// per line and optionally followed by ":count". Only the range file of the
+// password is read, so its lines need not be sorted, and a missing range
+// file means no leaked password starts with that prefix.
+type Breached struct {
+	dir string
+}
+
+func OpenBreached(dir string) (*Breached, error) {
+	const op = "passwordpolicy.OpenBreached"
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: %s is not a directory of range files", op, dir)
+	}
+	return &Breached{dir: dir}, nil
+}
+
+// Contains reports whether the SHA-1 of password is in the list.
+func (b *Breached) Contains(password string) (bool, error) {
+	const op = "passwordpolicy.Breached.Contains"
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	f, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return false, nil
+}
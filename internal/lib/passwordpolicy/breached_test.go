@@ -0,0 +1,90 @@
+package passwordpolicy_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// writeRanges writes one range file per prefix with the given lines.
+func writeRanges(t *testing.T, ranges map[string][]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for prefix, lines := range ranges {
+		content := strings.Join(lines, "\r\n") + "\r\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(content), 0o600))
+	}
+	return dir
+}
+
+func TestBreached_Contains(t *testing.T) {
+	// "password" and "123456" share no prefix; "password1" is given a
+	// made-up neighbour in its own range file.
+	password, first, last := sha1Hex("password"), sha1Hex("123456"), sha1Hex("password1")
+	neighbour := last[:5] + strings.Repeat("0", 35)
+
+	dir := writeRanges(t, map[string][]string{
+		first[:5]:    {first[5:] + ":37359195", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1"},
+		password[:5]: {"00000000000000000000000000000000000:2", strings.ToLower(password[5:]), "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1"},
+		// Not sorted: the matching line comes after a larger one.
+		last[:5]: {"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1", neighbour[5:] + ":4", last[5:] + ":9"},
+	})
+
+	b, err := passwordpolicy.OpenBreached(dir)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{name: "first entry", password: "123456", want: true},
+		{name: "middle entry without count, lower case", password: "password", want: true},
+		{name: "last entry of an unsorted file", password: "password1", want: true},
+		{name: "missing range file", password: "correct horse battery staple", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := b.Contains(tc.password)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBreached_MissingHashInRange(t *testing.T) {
+	hash := sha1Hex("password")
+	dir := writeRanges(t, map[string][]string{
+		hash[:5]: {"00000000000000000000000000000000000:2", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF:1"},
+	})
+
+	b, err := passwordpolicy.OpenBreached(dir)
+	require.NoError(t, err)
+
+	got, err := b.Contains("password")
+	require.NoError(t, err)
+	require.False(t, got)
+}
+
+func TestOpenBreached(t *testing.T) {
+	_, err := passwordpolicy.OpenBreached(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "list.txt")
+	require.NoError(t, os.WriteFile(file, []byte(sha1Hex("password")+"\n"), 0o600))
+	_, err = passwordpolicy.OpenBreached(file)
+	require.Error(t, err, "a single sorted file is not a range directory")
+}
@@ -0,0 +1,169 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+)
+
+var (
+	ErrWeakPassword     = errors.New("password does not meet the policy")
+	ErrUnknownCharClass = errors.New("unknown character class")
+)
+
+// Rule names reported in a Violation.
+const (
+	RuleMinLength     = "min_length"
+	RuleMaxLength     = "max_length"
+	RuleCharClass     = "char_class"
+	RuleContainsLogin = "contains_login"
+	RuleContainsEmail = "contains_email"
+	RuleStrength      = "strength"
+	RuleBreached      = "breached"
+)
+
+// minIdentityLength keeps very short logins from ruling out half the alphabet.
+const minIdentityLength = 3
+
+type charClass struct {
+	name string
+	in   func(rune) bool
+}
+
+var charClasses = map[string]charClass{
+	"lower":  {"lowercase letter", unicode.IsLower},
+	"upper":  {"uppercase letter", unicode.IsUpper},
+	"digit":  {"digit", unicode.IsDigit},
+	"symbol": {"symbol", func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r) }},
+}
+
+// Violation is one rule a password failed.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Error lists every rule a password failed. It matches ErrWeakPassword.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(msgs, "; "))
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// Policy is applied wherever a password is chosen: registration,
+// password reset and password change.
+type Policy struct {
+	MinLength     int
+	MaxLength     int
+	CharClasses   []string
+	AllowIdentity bool
+	MinScore      int
+	// Breached is nil when no breached password list is configured.
+	Breached *Breached
+}
+
+func New(cfg *config.Config) (*Policy, error) {
+	const op = "passwordpolicy.New"
+
+	c := cfg.Password.Policy
+	for _, class := range c.CharClasses {
+		if _, ok := charClasses[class]; !ok {
+			return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownCharClass, class)
+		}
+	}
+
+	p := &Policy{
+		MinLength:     c.MinLength,
+		MaxLength:     c.MaxLength,
+		CharClasses:   c.CharClasses,
+		AllowIdentity: c.AllowIdentity,
+		MinScore:      c.MinScore,
+	}
+	if c.BreachedList != "" {
+		breached, err := OpenBreached(c.BreachedList)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		p.Breached = breached
+	}
+	return p, nil
+}
+
+// Check returns an *Error listing every rule password breaks for the
+// account with the given login and email. Other errors come from reading
+// the breached password list.
+func (p *Policy) Check(password, login, email string) error {
+	const op = "passwordpolicy.Check"
+
+	var violations []Violation
+	fail := func(rule, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		fail(RuleMinLength, "shorter than %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		// Nothing else is worth checking, and scoring or hashing an
+		// arbitrarily long input would only cost CPU.
+		fail(RuleMaxLength, "longer than %d bytes", p.MaxLength)
+		return &Error{Violations: violations}
+	}
+
+	for _, name := range p.CharClasses {
+		if class := charClasses[name]; !strings.ContainsFunc(password, class.in) {
+			fail(RuleCharClass, "no %s", class.name)
+		}
+	}
+
+	lower := strings.ToLower(password)
+	localPart, _, _ := strings.Cut(email, "@")
+	if !p.AllowIdentity {
+		if containsIdentity(lower, login) {
+			fail(RuleContainsLogin, "contains the login")
+		}
+		if containsIdentity(lower, localPart) {
+			fail(RuleContainsEmail, "contains the email address")
+		}
+	}
+
+	if score := Score(password, login, localPart); score < p.MinScore {
+		fail(RuleStrength, "too easy to guess (strength %d of 4, at least %d required)", score, p.MinScore)
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if breached {
+			fail(RuleBreached, "appears in a list of leaked passwords")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+func containsIdentity(lowerPassword, identity string) bool {
+	if utf8.RuneCountInString(identity) < minIdentityLength {
+		return false
+	}
+	return strings.Contains(lowerPassword, strings.ToLower(identity))
+}
@@ -0,0 +1,26 @@
+package passwordpolicy_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+)
+
+func TestCheck_OversizedPasswordReturnsQuickly(t *testing.T) {
+	cfg := &config.Config{Password: config.Password{Policy: config.Policy{MinLength: 8, MaxLength: 72, MinScore: 2}}}
+	p, err := passwordpolicy.New(cfg)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = p.Check(strings.Repeat("x", 2000), "test_user", "test@example.com")
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+
+	var policyErr *passwordpolicy.Error
+	require.ErrorAs(t, err, &policyErr)
+	require.Equal(t, []passwordpolicy.Violation{{Rule: passwordpolicy.RuleMaxLength, Message: "longer than 72 bytes"}}, policyErr.Violations)
+}
@@ -0,0 +1,196 @@
+package passwordpolicy
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"unicode"
+)
+
+// common.txt holds frequent passwords and words, most common first.
+//
+//go:embed common.txt
+var commonList string
+
+var commonRanks = loadRanks(commonList)
+
+// maxWordLength is the longest common word or keyboard row; no longer
+// substring can be one of them.
+var maxWordLength = longestWord(commonRanks, keyboardRows)
+
+// bruteforceGuesses is what one character costs when no pattern covers it.
+const bruteforceGuesses = 10
+
+// minPatternLength is the shortest run counted as a pattern.
+const minPatternLength = 3
+
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890", "qazwsx", "1qaz2wsx"}
+
+var leet = strings.NewReplacer("4", "a", "@", "a", "8", "b", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+// Score estimates how hard password is to guess, in the spirit of zxcvbn:
+// the password is split into the cheapest sequence of known patterns
+// (common words, the user's own inputs, repeats, sequences, keyboard walks)
+// and brute-forced characters, and the guesses needed are bucketed into
+// 0 (trivial) to 4 (strong).
+func Score(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputs)
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// estimateGuesses finds, for every prefix, the cheapest way to guess it.
+// Words are only looked for up to the longest one that could match, and
+// repeats and sequences are followed as runs, so the cost grows linearly
+// with the length of password.
+func estimateGuesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 1
+	}
+
+	longest := maxWordLength
+	inputs := make(map[string]bool, len(userInputs))
+	for _, in := range userInputs {
+		if l := len([]rune(in)); l >= minPatternLength {
+			inputs[strings.ToLower(in)] = true
+			longest = max(longest, l)
+		}
+	}
+
+	best := make([]float64, n+1)
+	best[0] = 1
+	// repeatStart and sequenceStart are where the runs ending at the
+	// current character begin.
+	repeatStart, sequenceStart := 0, 0
+	for end := 1; end <= n; end++ {
+		i := end - 1
+		if i > 0 && runes[i] != runes[i-1] {
+			repeatStart = i
+		}
+		if i >= 2 && runes[i]-runes[i-1] != runes[i-1]-runes[i-2] {
+			sequenceStart = i - 1
+		}
+
+		best[end] = best[end-1] * bruteforceGuesses
+		for start := max(0, end-longest); start <= end-minPatternLength; start++ {
+			if g := wordGuesses(runes[start:end], inputs); g > 0 {
+				best[end] = min(best[end], best[start]*g)
+			}
+		}
+		if run := end - repeatStart; run >= minPatternLength {
+			best[end] = min(best[end], best[repeatStart]*bruteforceGuesses*float64(run))
+		}
+		if run := runes[sequenceStart:end]; len(run) >= minPatternLength {
+			if g := sequenceGuesses(run); g > 0 {
+				best[end] = min(best[end], best[sequenceStart]*g)
+			}
+		}
+	}
+	return best[n]
+}
+
+// wordGuesses returns the guesses needed for s as a user input, a common
+// word or a keyboard walk, or 0 if s is none of them.
+func wordGuesses(s []rune, inputs map[string]bool) float64 {
+	word := string(s)
+	lower := strings.ToLower(word)
+	n := float64(len(s))
+
+	if inputs[lower] {
+		return 1
+	}
+	if rank, ok := commonRanks[lower]; ok {
+		return float64(rank) * caseVariations(word)
+	}
+	if unleeted := leet.Replace(lower); unleeted != lower {
+		if rank, ok := commonRanks[unleeted]; ok {
+			return float64(rank) * caseVariations(word) * 2
+		}
+	}
+	if len(s) >= 4 && isKeyboardWalk(lower) {
+		return 40 * n
+	}
+	return 0
+}
+
+// sequenceGuesses returns the guesses needed for a run with a constant
+// step, or 0 if the step is too large to be a sequence.
+func sequenceGuesses(s []rune) float64 {
+	delta := s[1] - s[0]
+	if delta == 0 || delta > 2 || delta < -2 {
+		return 0
+	}
+	base := 26.0
+	if unicode.IsDigit(s[0]) {
+		base = 10
+	}
+	if delta < 0 {
+		base *= 2
+	}
+	return base * float64(len(s))
+}
+
+func caseVariations(word string) float64 {
+	if strings.ToLower(word) == word {
+		return 1
+	}
+	if r := []rune(word); unicode.IsUpper(r[0]) && strings.ToLower(string(r[1:])) == string(r[1:]) {
+		return 2
+	}
+	return 8
+}
+
+func isKeyboardWalk(lower string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, lower) || strings.Contains(reverse(row), lower) {
+			return true
+		}
+	}
+	return false
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func loadRanks(list string) map[string]int {
+	ranks := make(map[string]int)
+	sc := bufio.NewScanner(strings.NewReader(list))
+	for sc.Scan() {
+		word := strings.TrimSpace(sc.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = len(ranks) + 1
+		}
+	}
+	return ranks
+}
+
+func longestWord(ranks map[string]int, rows []string) int {
+	longest := 0
+	for word := range ranks {
+		longest = max(longest, len([]rune(word)))
+	}
+	for _, row := range rows {
+		longest = max(longest, len([]rune(row)))
+	}
+	return longest
+}
@@ -0,0 +1,54 @@
+package passwordpolicy_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		min        int
+		max        int
+	}{
+		{name: "empty", password: "", min: 0, max: 0},
+		{name: "most common", password: "password", min: 0, max: 0},
+		{name: "common with capital", password: "Password", min: 0, max: 0},
+		{name: "leet common", password: "p@ssw0rd", min: 0, max: 1},
+		{name: "repeat", password: "aaaaaaaaaaaa", min: 0, max: 0},
+		{name: "sequence", password: "abcdefghijkl", min: 0, max: 0},
+		{name: "keyboard walk", password: "qwertyuiop", min: 0, max: 0},
+		{name: "login inside", password: "jsmith2jsmith", userInputs: []string{"jsmith"}, min: 0, max: 1},
+		{name: "random", password: "v7#Kq9!mZ2xr", min: 4, max: 4},
+		{name: "passphrase", password: "correct horse battery staple", min: 4, max: 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			score := passwordpolicy.Score(tc.password, tc.userInputs...)
+			require.GreaterOrEqual(t, score, tc.min)
+			require.LessOrEqual(t, score, tc.max)
+		})
+	}
+}
+
+func TestScore_UserInputsLowerScore(t *testing.T) {
+	without := passwordpolicy.Score("zorbulax1987")
+	with := passwordpolicy.Score("zorbulax1987", "zorbulax")
+	require.Less(t, with, without)
+}
+
+func TestScore_LongPasswordIsFast(t *testing.T) {
+	password := strings.Repeat("aZ3$qwertyuiopabcdef", 500)
+
+	start := time.Now()
+	passwordpolicy.Score(password, "test_user")
+	require.Less(t, time.Since(start), time.Second)
+}
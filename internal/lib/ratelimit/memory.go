@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps the buckets in this process. Each replica then enforces
+// the budgets on its own; use the PostgreSQL backend to share them.
+type Memory struct {
+	mu sync.Mutex
+	// full is when each bucket holds its whole burst again.
+	full map[string]time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{full: make(map[string]time.Time)}
+}
+
+func (m *Memory) TakeToken(_ context.Context, key string, every time.Duration, burst int) (time.Duration, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	full := m.full[key]
+	if full.Before(now) {
+		full = now
+	}
+	full = full.Add(every)
+	if over := full.Sub(now) - time.Duration(burst)*every; over > 0 {
+		return over, nil
+	}
+	m.full[key] = full
+	return 0, nil
+}
+
+func (m *Memory) PurgeRateLimits(_ context.Context) (int64, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for key, full := range m.full {
+		if !full.After(now) {
+			delete(m.full, key)
+			n++
+		}
+	}
+	return n, nil
+}
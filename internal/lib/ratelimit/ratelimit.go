@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+)
+
+var ErrUnknownBackend = errors.New("unknown rate limit backend")
+
+// Store keeps token buckets by key. A bucket is stored as the time it
+// will be full again, so a full bucket needs no entry at all.
+type Store interface {
+	// TakeToken spends one request from the bucket of key. It returns zero
+	// if the request fits, or how long until it would.
+	TakeToken(ctx context.Context, key string, every time.Duration, burst int) (time.Duration, error)
+	PurgeRateLimits(ctx context.Context) (int64, error)
+}
+
+// New returns the backend named in cfg. The PostgreSQL backend is db itself.
+func New(cfg *config.Config, db Store) (Store, error) {
+	const op = "ratelimit.New"
+
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		return NewMemory(), nil
+	case "postgres":
+		return db, nil
+	}
+	return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownBackend, cfg.RateLimit.Backend)
+}
+
+// Limiter applies the per-method budgets of cfg.
+type Limiter struct {
+	Store Store
+	Cfg   config.RateLimit
+}
+
+// Budget returns the budget of method, given by its RPC name.
+func (l *Limiter) Budget(method string) config.Budget {
+	if b, ok := l.Cfg.Methods[method]; ok {
+		return b
+	}
+	return l.Cfg.Default
+}
+
+// Allow spends one call of method from the bucket of ip and, when the
+// budget is per subject and subject is not empty, from the bucket of
+// subject. It returns zero if the call may go ahead, or how long to wait.
+func (l *Limiter) Allow(ctx context.Context, method, ip, subject string) (time.Duration, error) {
+	const op = "ratelimit.Allow"
+
+	b := l.Budget(method)
+	if b.Every <= 0 {
+		return 0, nil
+	}
+
+	keys := []string{method + "|ip:" + ip}
+	if b.PerSubject && subject != "" {
+		keys = append(keys, method+"|sub:"+subject)
+	}
+	for _, key := range keys {
+		wait, err := l.Store.TakeToken(ctx, key, b.Every, max(b.Burst, 1))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if wait > 0 {
+			return wait, nil
+		}
+	}
+	return 0, nil
+}
+
+// RunPurger drops full buckets every interval until ctx is done.
+func RunPurger(ctx context.Context, log *slog.Logger, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.PurgeRateLimits(ctx)
+			if err != nil {
+				log.Error("failed to purge rate limits", logger.Err(err))
+				continue
+			}
+			if n > 0 {
+				log.Debug("purged rate limits", slog.Int64("count", n))
+			}
+		}
+	}
+}
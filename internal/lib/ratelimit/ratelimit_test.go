@@ -0,0 +1,128 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+)
+
+func take(t *testing.T, m *ratelimit.Memory, key string, every time.Duration, burst int) time.Duration {
+	t.Helper()
+	wait, err := m.TakeToken(context.Background(), key, every, burst)
+	require.NoError(t, err)
+	return wait
+}
+
+func TestMemory_Burst(t *testing.T) {
+	m := ratelimit.NewMemory()
+
+	for range 3 {
+		require.Zero(t, take(t, m, "key", time.Minute, 3))
+	}
+
+	wait := take(t, m, "key", time.Minute, 3)
+	require.Greater(t, wait, 59*time.Second, "the next token comes one interval after the first was spent")
+	require.LessOrEqual(t, wait, time.Minute)
+
+	require.Positive(t, take(t, m, "key", time.Minute, 3), "a refused call is not charged")
+	require.Zero(t, take(t, m, "other", time.Minute, 3), "keys have their own buckets")
+}
+
+func TestMemory_Refill(t *testing.T) {
+	m := ratelimit.NewMemory()
+	every := 100 * time.Millisecond
+
+	require.Zero(t, take(t, m, "key", every, 2))
+	require.Zero(t, take(t, m, "key", every, 2))
+	require.Positive(t, take(t, m, "key", every, 2))
+
+	time.Sleep(every + 10*time.Millisecond)
+	require.Zero(t, take(t, m, "key", every, 2), "one token is back after one interval")
+	require.Positive(t, take(t, m, "key", every, 2), "but only one")
+
+	time.Sleep(2*every + 10*time.Millisecond)
+	require.Zero(t, take(t, m, "key", every, 2))
+	require.Zero(t, take(t, m, "key", every, 2), "the bucket refills up to the burst")
+	require.Positive(t, take(t, m, "key", every, 2))
+}
+
+func TestMemory_Purge(t *testing.T) {
+	m := ratelimit.NewMemory()
+	every := 10 * time.Millisecond
+
+	take(t, m, "short", every, 1)
+	take(t, m, "long", time.Hour, 1)
+	time.Sleep(2 * every)
+
+	n, err := m.PurgeRateLimits(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n, "only full buckets are dropped")
+	require.Positive(t, take(t, m, "long", time.Hour, 1))
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	ctx := context.Background()
+	limiter := &ratelimit.Limiter{
+		Store: ratelimit.NewMemory(),
+		Cfg: config.RateLimit{
+			Default: config.Budget{Every: time.Minute, Burst: 2},
+			Methods: map[string]config.Budget{
+				"GetJWKS":        {},
+				"ChangePassword": {Every: time.Minute, Burst: 1, PerSubject: true},
+			},
+		},
+	}
+	allow := func(method, ip, subject string) bool {
+		t.Helper()
+		wait, err := limiter.Allow(ctx, method, ip, subject)
+		require.NoError(t, err)
+		return wait == 0
+	}
+
+	t.Run("burst then denied", func(t *testing.T) {
+		require.True(t, allow("Authenticate", "10.0.0.1", ""))
+		require.True(t, allow("Authenticate", "10.0.0.1", ""))
+		require.False(t, allow("Authenticate", "10.0.0.1", ""))
+		require.True(t, allow("Authenticate", "10.0.0.2", ""), "buckets are per ip")
+		require.True(t, allow("CreateUser", "10.0.0.1", ""), "buckets are per method")
+	})
+
+	t.Run("zero budget is not limited", func(t *testing.T) {
+		for range 5 {
+			require.True(t, allow("GetJWKS", "10.0.0.1", ""))
+		}
+	})
+
+	t.Run("per subject", func(t *testing.T) {
+		require.True(t, allow("ChangePassword", "10.0.1.1", "alice"))
+		require.False(t, allow("ChangePassword", "10.0.1.2", "alice"), "the login bucket follows it across ips")
+		require.True(t, allow("ChangePassword", "10.0.1.3", "bob"))
+		require.True(t, allow("ChangePassword", "10.0.1.4", ""), "calls without a subject only use the ip bucket")
+	})
+}
+
+type failingStore struct{}
+
+func (failingStore) TakeToken(context.Context, string, time.Duration, int) (time.Duration, error) {
+	return 0, errors.New("store is down")
+}
+
+func (failingStore) PurgeRateLimits(context.Context) (int64, error) {
+	return 0, errors.New("store is down")
+}
+
+func TestLimiter_StoreError(t *testing.T) {
+	limiter := &ratelimit.Limiter{
+		Store: failingStore{},
+		Cfg:   config.RateLimit{Default: config.Budget{Every: time.Minute, Burst: 1}},
+	}
+
+	_, err := limiter.Allow(context.Background(), "Authenticate", "10.0.0.1", "")
+	require.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package revocation
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrFull is returned when the memory store has no room for another token
+// without forgetting one that can still be used.
+var ErrFull = errors.New("revocation store is full")
+
+// Memory keeps revoked token ids in process until the tokens expire. It is
+// bounded by capacity, but only expired entries are ever dropped to make
+// room: when every entry is still live, RevokeToken fails with ErrFull
+// instead of silently un-revoking a token. Use the PostgreSQL backend when
+// revocations may outgrow capacity or several instances must share them.
+type Memory struct {
+	mu       sync.Mutex
+	capacity int
+	log      *slog.Logger
+	byExpiry expiryHeap
+	items    map[string]*entry
+}
+
+type entry struct {
+	jti       string
+	expiresAt time.Time
+	index     int
+}
+
+// NewMemory returns a store for up to capacity tokens; zero means unbounded.
+func NewMemory(capacity int, log *slog.Logger) *Memory {
+	return &Memory{
+		capacity: capacity,
+		log:      log,
+		items:    make(map[string]*entry),
+	}
+}
+
+func (m *Memory) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[jti]; ok {
+		if expiresAt.After(e.expiresAt) {
+			e.expiresAt = expiresAt
+			heap.Fix(&m.byExpiry, e.index)
+		}
+		return nil
+	}
+
+	if m.capacity > 0 && len(m.items) >= m.capacity {
+		m.dropExpired(time.Now())
+		if len(m.items) >= m.capacity {
+			m.log.Error("revocation store is full, token not revoked", slog.Int("capacity", m.capacity))
+			return ErrFull
+		}
+	}
+
+	e := &entry{jti: jti, expiresAt: expiresAt}
+	heap.Push(&m.byExpiry, e)
+	m.items[jti] = e
+	return nil
+}
+
+func (m *Memory) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.items[jti]
+	if !ok {
+		return false, nil
+	}
+	return time.Now().Before(e.expiresAt), nil
+}
+
+func (m *Memory) PurgeRevokedTokens(_ context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.dropExpired(time.Now()), nil
+}
+
+// dropExpired removes entries whose tokens have expired by now.
+func (m *Memory) dropExpired(now time.Time) int64 {
+	var n int64
+	for len(m.byExpiry) > 0 && !now.Before(m.byExpiry[0].expiresAt) {
+		e := heap.Pop(&m.byExpiry).(*entry)
+		delete(m.items, e.jti)
+		n++
+	}
+	return n
+}
+
+// expiryHeap orders entries by expiry, soonest first.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,79 @@
+package revocation_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+)
+
+func TestMemory_Expiry(t *testing.T) {
+	ctx := context.Background()
+	store := revocation.NewMemory(0, slogdiscard.NewDiscardLogger())
+
+	require.NoError(t, store.RevokeToken(ctx, "live", time.Now().Add(time.Hour)))
+	require.NoError(t, store.RevokeToken(ctx, "expired", time.Now().Add(-time.Second)))
+
+	revoked, err := store.IsTokenRevoked(ctx, "live")
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = store.IsTokenRevoked(ctx, "expired")
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	revoked, err = store.IsTokenRevoked(ctx, "unknown")
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	n, err := store.PurgeRevokedTokens(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestMemory_Capacity(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("full of live tokens fails closed", func(t *testing.T) {
+		store := revocation.NewMemory(3, slogdiscard.NewDiscardLogger())
+		for i := range 3 {
+			require.NoError(t, store.RevokeToken(ctx, fmt.Sprintf("jti-%d", i), time.Now().Add(time.Hour)))
+		}
+
+		require.ErrorIs(t, store.RevokeToken(ctx, "jti-3", time.Now().Add(time.Hour)), revocation.ErrFull)
+
+		for i := range 3 {
+			revoked, err := store.IsTokenRevoked(ctx, fmt.Sprintf("jti-%d", i))
+			require.NoError(t, err)
+			require.True(t, revoked, "no live token is forgotten")
+		}
+	})
+
+	t.Run("expired tokens make room", func(t *testing.T) {
+		store := revocation.NewMemory(3, slogdiscard.NewDiscardLogger())
+		require.NoError(t, store.RevokeToken(ctx, "old", time.Now().Add(-time.Second)))
+		require.NoError(t, store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Hour)))
+		require.NoError(t, store.RevokeToken(ctx, "jti-2", time.Now().Add(2*time.Hour)))
+
+		require.NoError(t, store.RevokeToken(ctx, "jti-3", time.Now().Add(time.Hour)))
+
+		for _, jti := range []string{"jti-1", "jti-2", "jti-3"} {
+			revoked, err := store.IsTokenRevoked(ctx, jti)
+			require.NoError(t, err)
+			require.True(t, revoked, jti)
+		}
+	})
+
+	t.Run("revoking again takes no room", func(t *testing.T) {
+		store := revocation.NewMemory(1, slogdiscard.NewDiscardLogger())
+		require.NoError(t, store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Minute)))
+		require.NoError(t, store.RevokeToken(ctx, "jti-1", time.Now().Add(time.Hour)))
+
+		require.ErrorIs(t, store.RevokeToken(ctx, "jti-2", time.Now().Add(time.Hour)), revocation.ErrFull)
+	})
+}
@@ -0,0 +1,107 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+)
+
+var ErrUnknownBackend = errors.New("unknown revocation backend")
+
+// Store remembers revoked token ids ("jti") until the token would have expired anyway.
+type Store interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeRevokedTokens(ctx context.Context) (int64, error)
+}
+
+// New returns the backend named in cfg. The PostgreSQL backend is db itself.
+func New(cfg *config.Config, log *slog.Logger, db Store) (Store, error) {
+	const op = "revocation.New"
+
+	switch cfg.Revocation.Backend {
+	case "memory":
+		return NewMemory(cfg.Revocation.Capacity, log), nil
+	case "postgres":
+		return db, nil
+	}
+	return nil, fmt.Errorf("%s: %w: %q", op, ErrUnknownBackend, cfg.Revocation.Backend)
+}
+
+// Check returns myjwt.ErrTokenRevoked if the token was revoked. Access and
+// refresh tokens always carry an id; myjwt refuses them otherwise.
+func Check(ctx context.Context, store Store, claims *myjwt.Claims) error {
+	const op = "revocation.Check"
+
+	revoked, err := store.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if revoked {
+		return myjwt.ErrTokenRevoked
+	}
+	return nil
+}
+
+// SessionGetter is the part of the storage CheckSession needs.
+type SessionGetter interface {
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+}
+
+// CheckSession returns myjwt.ErrTokenRevoked unless the session of claims
+// still exists and belongs to userId. Revoking a session deletes its row,
+// which this way ends its access tokens too, not just its refresh token.
+func CheckSession(ctx context.Context, sessions SessionGetter, claims *myjwt.Claims, userId int64) error {
+	const op = "revocation.CheckSession"
+
+	session, err := sessions.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return myjwt.ErrTokenRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if session.UserId != userId {
+		return myjwt.ErrTokenRevoked
+	}
+	return nil
+}
+
+// Revoke stores the token id until the token's own expiry.
+func Revoke(ctx context.Context, store Store, claims *myjwt.Claims) error {
+	const op = "revocation.Revoke"
+
+	if err := store.RevokeToken(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RunPurger drops expired entries every interval until ctx is done.
+func RunPurger(ctx context.Context, log *slog.Logger, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.PurgeRevokedTokens(ctx)
+			if err != nil {
+				log.Error("failed to purge revoked tokens", logger.Err(err))
+				continue
+			}
+			if n > 0 {
+				log.Debug("purged revoked tokens", slog.Int64("count", n))
+			}
+		}
+	}
+}
@@ -4,62 +4,150 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 
 	"github.com/Weit145/Auth_golang/internal/config"
 	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
 	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
 	"github.com/Weit145/Auth_golang/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
 
+// RehashedTotal counts passwords rewritten with the current hashing policy.
+var RehashedTotal = expvar.NewInt("auth_password_rehashed_total")
+
 type Login struct {
 	Storage    AuthRepo
 	TxProvider storage.TxProvider
+	Hasher     *hasher.Hasher
+	Lockout    *lockout.Guard
+	Keys       *myjwt.Keys
 	Cfg        *config.Config
 	Log        *slog.Logger
 }
 
 type AuthRepo interface {
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
-	AuthenticateRepo(ctx context.Context, user *domain.User) error
+	CreateSession(ctx context.Context, session *domain.Session) error
+	UpdatePasswordHash(ctx context.Context, user *domain.User) error
 }
 
 func (s Login) LoginUser(ctx context.Context, login, password string) (accessToken, refreshToken string, err error) {
 	const op = "service.LoginUser"
+	log := logger.FromContext(ctx, s.Log)
+
+	// Only normalized, not validated: accounts created under older rules
+	// must still be able to sign in.
+	login = identity.NormalizeLogin(login)
+
+	// The attempt is counted before the password is checked, so parallel
+	// guesses cannot get past the threshold.
+	client := clientinfo.FromContext(ctx)
+	if err = s.Lockout.Attempt(ctx, login, client.IP); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
 
+	var passwordVerified bool
 	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
 		user, err := s.Storage.GetUserByLogin(ctx, login)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			s.Hasher.VerifyDummy(password)
+			return fmt.Errorf("%s: %w", op, domain.ErrInvalidCredentials)
+		}
 		if err != nil {
 			return fmt.Errorf("%s: failed to get user by login within transaction: %w", op, err)
 		}
 
-		refreshToken, err = myjwt.CreateLoginJWT(s.Cfg, s.Log, user.Login)
+		if err = s.Hasher.Verify(user.PasswordHash, password); err != nil {
+			if errors.Is(err, hasher.ErrMismatch) {
+				return fmt.Errorf("%s: %w", op, domain.ErrInvalidCredentials)
+			}
+			return fmt.Errorf("%s: failed to verify password: %w", op, err)
+		}
+		passwordVerified = true
+
+		// Checked only after the password, so the account state is never
+		// revealed to someone guessing it.
+		scope, err := account.Check(s.Cfg.Account, user)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if s.Hasher.NeedsRehash(user.PasswordHash) {
+			if err = s.rehash(ctx, user, password); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		session := &domain.Session{
+			Id:        myjwt.NewSessionID(),
+			UserId:    user.Id,
+			UserAgent: client.UserAgent,
+			IP:        client.IP,
+		}
+
+		tokenClaims := myjwt.Claims{Login: user.Login, SessionID: session.Id, Generation: user.TokenGeneration, Scope: scope}
+		refreshToken, err = myjwt.CreateRefreshToken(s.Cfg, s.Keys, log, tokenClaims)
 		if err != nil {
-			return fmt.Errorf("%s: failed to create login JWT: %w", op, err)
+			return fmt.Errorf("%s: failed to create refresh JWT: %w", op, err)
 		}
 
-		accessToken, err = myjwt.CreateLoginJWT(s.Cfg, s.Log, user.Login)
+		accessToken, err = myjwt.CreateAccessToken(s.Cfg, s.Keys, log, tokenClaims)
 		if err != nil {
-			s.Log.Error("failed to create login JWT", logger.Err(err))
+			log.Error("failed to create access JWT", logger.Err(err))
 			return fmt.Errorf("%s: %w", op, err)
 		}
 		h := sha256.New()
 		h.Write([]byte(refreshToken))
-		user.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
+		session.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
 
-		if err = s.Storage.AuthenticateRepo(ctx, user); err != nil {
-			return fmt.Errorf("%s: failed to authenticate user within transaction: %w", op, err)
+		if err = s.Storage.CreateSession(ctx, session); err != nil {
+			return fmt.Errorf("%s: failed to create session within transaction: %w", op, err)
 		}
 
-		s.Log.Info("Authenticate method called", slog.String("Login: ", login))
+		log.Info("Authenticate method called", slog.String("login", login))
 		return nil
 	})
+	// A right password is not a failed guess, even if the account may
+	// not sign in.
+	if passwordVerified {
+		if lockErr := s.Lockout.Succeed(ctx, login, client.IP); lockErr != nil {
+			log.Error("failed to clear failed logins", logger.Err(lockErr))
+		}
+	}
 	if err != nil {
 		return "", "", err
 	}
 
 	return accessToken, refreshToken, nil
 }
+
+func (s Login) rehash(ctx context.Context, user *domain.User, password string) error {
+	const op = "service.rehash"
+	log := logger.FromContext(ctx, s.Log)
+
+	passwordHash, err := s.Hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("%s: failed to hash password: %w", op, err)
+	}
+	user.PasswordHash = passwordHash
+
+	if err = s.Storage.UpdatePasswordHash(ctx, user); err != nil {
+		return fmt.Errorf("%s: failed to update password hash: %w", op, err)
+	}
+
+	RehashedTotal.Add(1)
+	log.Info("password rehashed", slog.Int64("user_id", user.Id), slog.Int64("rehashed_total", RehashedTotal.Value()))
+	return nil
+}
@@ -0,0 +1,232 @@
+package authenticate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/service/authenticate"
+	"github.com/jackc/pgx/v5"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+type fakeAuthRepo struct {
+	users    map[string]*domain.User
+	sessions []*domain.Session
+}
+
+func (r *fakeAuthRepo) GetUserByLogin(_ context.Context, login string) (*domain.User, error) {
+	user, ok := r.users[login]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) CreateSession(_ context.Context, session *domain.Session) error {
+	r.sessions = append(r.sessions, session)
+	return nil
+}
+
+func (r *fakeAuthRepo) UpdatePasswordHash(_ context.Context, user *domain.User) error {
+	r.users[user.Login].PasswordHash = user.PasswordHash
+	return nil
+}
+
+type fakeLockoutStore struct {
+	attempts map[string]domain.LoginAttempts
+}
+
+func (s *fakeLockoutStore) LoginAttemptsForUpdate(_ context.Context, key string) (*domain.LoginAttempts, error) {
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = domain.LoginAttempts{Key: key, LastFailureAt: time.Now()}
+	}
+	return &attempts, nil
+}
+
+func (s *fakeLockoutStore) SaveLoginAttempts(_ context.Context, attempts *domain.LoginAttempts) error {
+	s.attempts[attempts.Key] = *attempts
+	return nil
+}
+
+func (s *fakeLockoutStore) ClearLoginFailures(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		delete(s.attempts, key)
+	}
+	return nil
+}
+
+func newLogin(t *testing.T, unverified string, users ...domain.User) (authenticate.Login, *fakeAuthRepo) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Access: time.Hour, Refresh: time.Hour},
+		Password: config.Password{Algorithm: "bcrypt", Bcrypt: config.Bcrypt{Cost: 4}},
+		Lockout:  config.Lockout{LoginThreshold: 5, IPThreshold: 20, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour},
+		Account:  config.Account{Unverified: unverified},
+	}
+
+	h, err := hasher.New(cfg)
+	require.NoError(t, err)
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+
+	repo := &fakeAuthRepo{users: make(map[string]*domain.User)}
+	for _, u := range users {
+		u.PasswordHash, err = h.Hash("password123")
+		require.NoError(t, err)
+		repo.users[u.Login] = &u
+	}
+
+	return authenticate.Login{
+		Storage:    repo,
+		TxProvider: fakeTx{},
+		Hasher:     h,
+		Lockout: &lockout.Guard{
+			Store:      &fakeLockoutStore{attempts: make(map[string]domain.LoginAttempts)},
+			TxProvider: fakeTx{},
+			Cfg:        cfg.Lockout,
+		},
+		Keys: keys,
+		Cfg:  cfg,
+		Log:  slogdiscard.NewDiscardLogger(),
+	}, repo
+}
+
+func TestLoginUser_AccountState(t *testing.T) {
+	tests := []struct {
+		name          string
+		unverified    string
+		user          domain.User
+		password      string
+		expectedErr   error
+		expectedScope string
+	}{
+		{
+			name:       "verified active user",
+			unverified: account.UnverifiedDeny,
+			user:       domain.User{Id: 1, Login: "test_user", IsActive: true, IsVerified: true},
+			password:   "password123",
+		},
+		{
+			name:        "unverified user denied",
+			unverified:  account.UnverifiedDeny,
+			user:        domain.User{Id: 1, Login: "test_user", IsActive: true},
+			password:    "password123",
+			expectedErr: domain.ErrNotVerified,
+		},
+		{
+			name:          "unverified user restricted",
+			unverified:    account.UnverifiedRestricted,
+			user:          domain.User{Id: 1, Login: "test_user", IsActive: true},
+			password:      "password123",
+			expectedScope: account.ScopeUnverified,
+		},
+		{
+			name:       "unverified user allowed",
+			unverified: account.UnverifiedAllow,
+			user:       domain.User{Id: 1, Login: "test_user", IsActive: true},
+			password:   "password123",
+		},
+		{
+			name:        "unknown mode denies",
+			unverified:  "maybe",
+			user:        domain.User{Id: 1, Login: "test_user", IsActive: true},
+			password:    "password123",
+			expectedErr: domain.ErrNotVerified,
+		},
+		{
+			name:        "deactivated user",
+			unverified:  account.UnverifiedAllow,
+			user:        domain.User{Id: 1, Login: "test_user", IsVerified: true},
+			password:    "password123",
+			expectedErr: domain.ErrInactive,
+		},
+		{
+			name:       "login from before the current rules",
+			unverified: account.UnverifiedDeny,
+			user:       domain.User{Id: 1, Login: "x!", IsActive: true, IsVerified: true},
+			password:   "password123",
+		},
+		{
+			name:        "wrong password hides account state",
+			unverified:  account.UnverifiedDeny,
+			user:        domain.User{Id: 1, Login: "test_user"},
+			password:    "wrong_password",
+			expectedErr: domain.ErrInvalidCredentials,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			login, repo := newLogin(t, tc.unverified, tc.user)
+
+			accessToken, refreshToken, err := login.LoginUser(context.Background(), tc.user.Login, tc.password)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				require.Empty(t, accessToken)
+				require.Empty(t, repo.sessions)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, repo.sessions, 1)
+
+			for _, token := range []struct {
+				value string
+				use   myjwt.TokenUse
+			}{{accessToken, myjwt.TokenAccess}, {refreshToken, myjwt.TokenRefresh}} {
+				claims, err := myjwt.GetClaims(token.value, login.Keys, token.use)
+				require.NoError(t, err)
+				require.Equal(t, tc.user.Login, claims.Login)
+				require.Equal(t, tc.expectedScope, claims.Scope)
+			}
+		})
+	}
+}
+
+func TestLoginUser_Lockout(t *testing.T) {
+	login, _ := newLogin(t, account.UnverifiedDeny,
+		domain.User{Id: 1, Login: "test_user", IsActive: true, IsVerified: true})
+	ctx := context.Background()
+
+	for range login.Cfg.Lockout.LoginThreshold {
+		_, _, err := login.LoginUser(ctx, "test_user", "wrong_password")
+		require.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	}
+
+	_, _, err := login.LoginUser(ctx, "test_user", "password123")
+	require.ErrorIs(t, err, lockout.ErrLocked, "even the right password waits out the lock")
+}
+
+func TestLoginUser_SuccessClearsFailures(t *testing.T) {
+	login, _ := newLogin(t, account.UnverifiedDeny,
+		domain.User{Id: 1, Login: "test_user", IsActive: true, IsVerified: true})
+	ctx := context.Background()
+
+	for range login.Cfg.Lockout.LoginThreshold - 1 {
+		_, _, err := login.LoginUser(ctx, "test_user", "wrong_password")
+		require.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	}
+	_, _, err := login.LoginUser(ctx, "test_user", "password123")
+	require.NoError(t, err)
+
+	_, _, err = login.LoginUser(ctx, "test_user", "wrong_password")
+	require.ErrorIs(t, err, domain.ErrInvalidCredentials)
+}
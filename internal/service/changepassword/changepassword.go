@@ -0,0 +1,167 @@
+package changepassword
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrWrongPassword = errors.New("current password is wrong")
+
+type ChangePassword struct {
+	Storage    ChangePasswordRepo
+	TxProvider storage.TxProvider
+	Hasher     *hasher.Hasher
+	Policy     *passwordpolicy.Policy
+	Lockout    *lockout.Guard
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
+	Cfg        *config.Config
+	Log        *slog.Logger
+}
+
+type ChangePasswordRepo interface {
+	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	ChangePassword(ctx context.Context, user *domain.User) error
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	RotateSession(ctx context.Context, session *domain.Session, oldHash string) (bool, error)
+	DeleteOtherSessions(ctx context.Context, userId int64, keepId string) error
+	DeleteUserSessions(ctx context.Context, userId int64) error
+}
+
+// ChangePassword replaces the password of the signed-in user. Changing it
+// bumps the token generation, so every session ends, including the current
+// one unless keepCurrentSession is set. A kept session gets a fresh token
+// pair; otherwise both returned tokens are empty. Wrong current passwords
+// count towards the same lockout as failed logins.
+func (s *ChangePassword) ChangePassword(ctx context.Context, AssetToken, oldPassword, newPassword string, keepCurrentSession bool) (accessToken, refreshToken string, err error) {
+	const op = "service.ChangePassword"
+	log := logger.FromContext(ctx, s.Log)
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get claims from token", logger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// A stolen access token must not allow more password guesses than
+	// the login form does.
+	client := clientinfo.FromContext(ctx)
+	if err = s.Lockout.Attempt(ctx, claims.Login, client.IP); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var passwordVerified bool
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get user by login within transaction: %w", op, err)
+		}
+		if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = account.CheckFull(s.Cfg.Account, user); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = revocation.CheckSession(ctx, s.Storage, claims, user.Id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err = s.Hasher.Verify(user.PasswordHash, oldPassword); err != nil {
+			if errors.Is(err, hasher.ErrMismatch) {
+				return fmt.Errorf("%s: %w", op, ErrWrongPassword)
+			}
+			return fmt.Errorf("%s: failed to verify password: %w", op, err)
+		}
+		passwordVerified = true
+
+		if err = s.Policy.Check(newPassword, user.Login, user.Email); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		user.PasswordHash, err = s.Hasher.Hash(newPassword)
+		if err != nil {
+			log.Error("failed to generate password hash", logger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = s.Storage.ChangePassword(ctx, user); err != nil {
+			return fmt.Errorf("%s: failed to change password within transaction: %w", op, err)
+		}
+
+		if !keepCurrentSession {
+			if err = s.Storage.DeleteUserSessions(ctx, user.Id); err != nil {
+				return fmt.Errorf("%s: failed to delete sessions within transaction: %w", op, err)
+			}
+			log.Info("ChangePassword method called", slog.String("login", user.Login))
+			return nil
+		}
+
+		session, err := s.Storage.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get session within transaction: %w", op, err)
+		}
+		if err = s.Storage.DeleteOtherSessions(ctx, user.Id, session.Id); err != nil {
+			return fmt.Errorf("%s: failed to delete sessions within transaction: %w", op, err)
+		}
+
+		tokenClaims := myjwt.Claims{Login: user.Login, SessionID: session.Id, Generation: user.TokenGeneration}
+		refreshToken, err = myjwt.CreateRefreshToken(s.Cfg, s.Keys, log, tokenClaims)
+		if err != nil {
+			return fmt.Errorf("%s: failed to create refresh JWT: %w", op, err)
+		}
+
+		accessToken, err = myjwt.CreateAccessToken(s.Cfg, s.Keys, log, tokenClaims)
+		if err != nil {
+			return fmt.Errorf("%s: failed to create access JWT: %w", op, err)
+		}
+
+		oldHash := session.RefreshTokenHash
+		h := sha256.New()
+		h.Write([]byte(refreshToken))
+		session.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
+
+		session.UserAgent = client.UserAgent
+		session.IP = client.IP
+
+		rotated, err := s.Storage.RotateSession(ctx, session, oldHash)
+		if err != nil {
+			return fmt.Errorf("%s: failed to rotate refresh token within transaction: %w", op, err)
+		}
+		if !rotated {
+			return fmt.Errorf("%s: %w", op, myjwt.ErrTokenRevoked)
+		}
+
+		log.Info("ChangePassword method called", slog.String("login", user.Login), slog.String("session_id", session.Id))
+		return nil
+	})
+	if passwordVerified {
+		if lockErr := s.Lockout.Succeed(ctx, claims.Login, client.IP); lockErr != nil {
+			log.Error("failed to clear failed logins", logger.Err(lockErr))
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
@@ -0,0 +1,202 @@
+package changepassword_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/service/changepassword"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+type fakeChangePasswordRepo struct {
+	user            domain.User
+	sessionsDeleted bool
+}
+
+func (r *fakeChangePasswordRepo) GetUserByLogin(_ context.Context, login string) (*domain.User, error) {
+	if login != r.user.Login {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakeChangePasswordRepo) ChangePassword(_ context.Context, user *domain.User) error {
+	r.user.PasswordHash = user.PasswordHash
+	return nil
+}
+
+func (r *fakeChangePasswordRepo) GetSession(_ context.Context, id string) (*domain.Session, error) {
+	if id != "session" {
+		return nil, domain.ErrSessionNotFound
+	}
+	return &domain.Session{Id: id, UserId: r.user.Id}, nil
+}
+
+func (r *fakeChangePasswordRepo) RotateSession(context.Context, *domain.Session, string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeChangePasswordRepo) DeleteOtherSessions(context.Context, int64, string) error {
+	return nil
+}
+
+func (r *fakeChangePasswordRepo) DeleteUserSessions(context.Context, int64) error {
+	r.sessionsDeleted = true
+	return nil
+}
+
+type fakeLockoutStore struct {
+	attempts map[string]domain.LoginAttempts
+}
+
+func (s *fakeLockoutStore) LoginAttemptsForUpdate(_ context.Context, key string) (*domain.LoginAttempts, error) {
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = domain.LoginAttempts{Key: key, LastFailureAt: time.Now()}
+	}
+	return &attempts, nil
+}
+
+func (s *fakeLockoutStore) SaveLoginAttempts(_ context.Context, attempts *domain.LoginAttempts) error {
+	s.attempts[attempts.Key] = *attempts
+	return nil
+}
+
+func (s *fakeLockoutStore) ClearLoginFailures(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		delete(s.attempts, key)
+	}
+	return nil
+}
+
+func newChangePassword(t *testing.T) (*changepassword.ChangePassword, *fakeChangePasswordRepo, string) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Access: time.Hour, Refresh: time.Hour},
+		Password: config.Password{
+			Algorithm: "bcrypt",
+			Bcrypt:    config.Bcrypt{Cost: 4},
+			Policy:    config.Policy{MinLength: 8, MaxLength: 72},
+		},
+		Lockout: config.Lockout{LoginThreshold: 3, IPThreshold: 20, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour},
+		Account: config.Account{Unverified: account.UnverifiedDeny},
+	}
+
+	h, err := hasher.New(cfg)
+	require.NoError(t, err)
+	policy, err := passwordpolicy.New(cfg)
+	require.NoError(t, err)
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+
+	repo := &fakeChangePasswordRepo{user: domain.User{Id: 1, Login: "test_user", Email: "test@example.com", IsActive: true, IsVerified: true}}
+	repo.user.PasswordHash, err = h.Hash("password123")
+	require.NoError(t, err)
+
+	token, err := myjwt.CreateAccessToken(cfg, keys, slogdiscard.NewDiscardLogger(), myjwt.Claims{Login: "test_user", SessionID: "session"})
+	require.NoError(t, err)
+
+	return &changepassword.ChangePassword{
+		Storage:    repo,
+		TxProvider: fakeTx{},
+		Hasher:     h,
+		Policy:     policy,
+		Lockout: &lockout.Guard{
+			Store:      &fakeLockoutStore{attempts: make(map[string]domain.LoginAttempts)},
+			TxProvider: fakeTx{},
+			Cfg:        cfg.Lockout,
+		},
+		Keys:    keys,
+		Revoked: revocation.NewMemory(0, slogdiscard.NewDiscardLogger()),
+		Cfg:     cfg,
+		Log:     slogdiscard.NewDiscardLogger(),
+	}, repo, token
+}
+
+func TestChangePassword_Lockout(t *testing.T) {
+	s, repo, token := newChangePassword(t)
+	ctx := context.Background()
+	oldHash := repo.user.PasswordHash
+
+	for range s.Cfg.Lockout.LoginThreshold {
+		_, _, err := s.ChangePassword(ctx, token, "wrong_password", "Tr0ub4dor&3xyz", false)
+		require.ErrorIs(t, err, changepassword.ErrWrongPassword)
+	}
+
+	_, _, err := s.ChangePassword(ctx, token, "password123", "Tr0ub4dor&3xyz", false)
+	require.ErrorIs(t, err, lockout.ErrLocked, "even the right password waits out the lock")
+	require.Equal(t, oldHash, repo.user.PasswordHash)
+}
+
+func TestChangePassword_SuccessClearsFailures(t *testing.T) {
+	s, repo, token := newChangePassword(t)
+	ctx := context.Background()
+
+	for range s.Cfg.Lockout.LoginThreshold - 1 {
+		_, _, err := s.ChangePassword(ctx, token, "wrong_password", "Tr0ub4dor&3xyz", false)
+		require.ErrorIs(t, err, changepassword.ErrWrongPassword)
+	}
+
+	accessToken, refreshToken, err := s.ChangePassword(ctx, token, "password123", "Tr0ub4dor&3xyz", false)
+	require.NoError(t, err)
+	require.Empty(t, accessToken)
+	require.Empty(t, refreshToken)
+	require.True(t, repo.sessionsDeleted)
+	require.NoError(t, s.Hasher.Verify(repo.user.PasswordHash, "Tr0ub4dor&3xyz"))
+
+	for range s.Cfg.Lockout.LoginThreshold - 1 {
+		_, _, err = s.ChangePassword(ctx, token, "wrong_password", "Tr0ub4dor&3xyz", false)
+		require.ErrorIs(t, err, changepassword.ErrWrongPassword, "the counter starts over")
+	}
+}
+
+func TestChangePassword_UnverifiedModes(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr error
+	}{
+		{mode: account.UnverifiedDeny, wantErr: domain.ErrNotVerified},
+		{mode: account.UnverifiedRestricted, wantErr: domain.ErrNotVerified},
+		{mode: account.UnverifiedAllow},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.mode, func(t *testing.T) {
+			s, repo, token := newChangePassword(t)
+			s.Cfg.Account.Unverified = tc.mode
+			repo.user.IsVerified = false
+			oldHash := repo.user.PasswordHash
+
+			_, _, err := s.ChangePassword(context.Background(), token, "password123", "Tr0ub4dor&3xyz", false)
+
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				require.Equal(t, oldHash, repo.user.PasswordHash)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEqual(t, oldHash, repo.user.PasswordHash)
+		})
+	}
+}
@@ -9,8 +9,10 @@ import (
 
 	"github.com/Weit145/Auth_golang/internal/config"
 	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
 	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
 	"github.com/Weit145/Auth_golang/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
@@ -18,6 +20,8 @@ import (
 type Confirm struct {
 	Storage    ConfirmRepo
 	TxProvider storage.TxProvider
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
 	Cfg        *config.Config
 	Log        *slog.Logger
 }
@@ -25,44 +29,70 @@ type Confirm struct {
 type ConfirmRepo interface {
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
 	ConfirmRepo(ctx context.Context, user *domain.User) error
+	CreateSession(ctx context.Context, session *domain.Session) error
 }
 
 func (s *Confirm) Confirm(ctx context.Context, token string) (accessToken, refreshToken string, err error) {
 	const op = "service.Confirm"
+	log := logger.FromContext(ctx, s.Log)
 
-	email, err := myjwt.GetEmail(token, s.Cfg.JWT.Secret)
+	claims, err := myjwt.GetVerificationClaims(token, s.Keys)
 	if err != nil {
-		s.Log.Error("failed to get email from token", slog.String("token", token), logger.Err(err))
+		log.Error("failed to get email from token", logger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
-		user, err := s.Storage.GetUserByEmail(ctx, email)
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.Storage.GetUserByEmail(ctx, claims.Email)
 		if err != nil {
 			return fmt.Errorf("%s: failed to get user by email within transaction: %w", op, err)
 		}
+		if err = user.CheckActive(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
 		user.IsVerified = true
 
-		refreshToken, err = myjwt.CreateLoginJWT(s.Cfg, s.Log, user.Login)
+		client := clientinfo.FromContext(ctx)
+		session := &domain.Session{
+			Id:        myjwt.NewSessionID(),
+			UserId:    user.Id,
+			UserAgent: client.UserAgent,
+			IP:        client.IP,
+		}
+
+		tokenClaims := myjwt.Claims{Login: user.Login, SessionID: session.Id, Generation: user.TokenGeneration}
+		refreshToken, err = myjwt.CreateRefreshToken(s.Cfg, s.Keys, log, tokenClaims)
 		if err != nil {
-			return fmt.Errorf("%s: failed to create login JWT: %w", op, err)
+			return fmt.Errorf("%s: failed to create refresh JWT: %w", op, err)
 		}
 
-		accessToken, err = myjwt.CreateLoginJWT(s.Cfg, s.Log, user.Login)
+		accessToken, err = myjwt.CreateAccessToken(s.Cfg, s.Keys, log, tokenClaims)
 		if err != nil {
-			s.Log.Error("failed to create login JWT", logger.Err(err))
+			log.Error("failed to create access JWT", logger.Err(err))
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
 		h := sha256.New()
 		h.Write([]byte(refreshToken))
-		user.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
+		session.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
 
 		if err = s.Storage.ConfirmRepo(ctx, user); err != nil {
 			return fmt.Errorf("%s: failed to update user within transaction: %w", op, err)
 		}
-		s.Log.Info("Confirm method called", slog.String("token: ", token))
+		if err = s.Storage.CreateSession(ctx, session); err != nil {
+			return fmt.Errorf("%s: failed to create session within transaction: %w", op, err)
+		}
+		// A confirmation link works once.
+		if err = revocation.Revoke(ctx, s.Revoked, claims); err != nil {
+			return fmt.Errorf("%s: failed to revoke verification token within transaction: %w", op, err)
+		}
+		log.Info("Confirm method called", slog.String("login", user.Login))
 		return nil
 	})
 	if err != nil {
@@ -7,8 +7,10 @@ import (
 
 	"github.com/Weit145/Auth_golang/internal/config"
 	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
 	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
 	"github.com/Weit145/Auth_golang/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
@@ -25,28 +27,44 @@ type Current struct {
 	Storage    CurrentRepo
 	TxProvider storage.TxProvider
 	Log        *slog.Logger
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
 	Cfg        *config.Config
 }
 
 type CurrentRepo interface {
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
 }
 
 func (s *Current) Current(ctx context.Context, AssetToken string) (*User, error) {
 	const op = "service.Current"
+	log := logger.FromContext(ctx, s.Log)
 
-	login, err := myjwt.GetLogin(AssetToken, s.Cfg.JWT.Secret)
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
 	if err != nil {
-		s.Log.Error("failed to get login from token", slog.String("token", AssetToken), logger.Err(err))
+		log.Error("failed to get login from token", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	var resp User
 	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
-		user, err := s.Storage.GetUserByLogin(ctx, login)
+		user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
 		if err != nil {
 			return fmt.Errorf("%s: failed to get user by login within transaction: %w", op, err)
 		}
+		if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err = account.Check(s.Cfg.Account, user); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = revocation.CheckSession(ctx, s.Storage, claims, user.Id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
 		resp = User{
 			Id:         int(user.Id),
@@ -55,7 +73,7 @@ func (s *Current) Current(ctx context.Context, AssetToken string) (*User, error)
 			IsVerified: user.IsVerified,
 			Role:       user.Role,
 		}
-		s.Log.Info("Current method called", slog.String("AssetToken: ", AssetToken))
+		log.Info("Current method called", slog.String("jti", claims.ID), slog.String("session_id", claims.SessionID))
 		return nil
 	})
 	if err != nil {
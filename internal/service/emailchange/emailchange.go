@@ -0,0 +1,257 @@
+package emailchange
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// KindEmailChange outbox messages carry a ChangeEmail.
+const KindEmailChange = "email_change"
+
+var (
+	ErrSameEmail               = errors.New("new email is the current email")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+)
+
+type ChangeEmail struct {
+	UserId   int64  `json:"user_id"`
+	Login    string `json:"login"`
+	OldEmail string `json:"old_email"`
+	NewEmail string `json:"new_email"`
+}
+
+type EmailChange struct {
+	Storage    EmailChangeRepo
+	TxProvider storage.TxProvider
+	Mailer     mailer.Mailer
+	Identity   *identity.Rules
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
+	Cfg        *config.Config
+	Log        *slog.Logger
+}
+
+type EmailChangeRepo interface {
+	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetUserById(ctx context.Context, id int64) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	EnqueueOutbox(ctx context.Context, kind string, payload []byte) error
+	UpdateEmail(ctx context.Context, user *domain.User) error
+	CreateEmailChange(ctx context.Context, userId int64, newEmail string, expiresAt time.Time) error
+	SetEmailChangeTokens(ctx context.Context, userId int64, newEmail, confirmHash, cancelHash string) (bool, error)
+	ConsumeEmailChange(ctx context.Context, confirmHash string) (int64, string, bool, error)
+	CancelEmailChange(ctx context.Context, cancelHash string) (bool, error)
+	DeletePasswordResets(ctx context.Context, userId int64) error
+}
+
+// RequestEmailChange stores newEmail as pending and queues the emails to
+// both addresses. The account keeps its current address until the new one
+// is confirmed; a newer request replaces an older pending one.
+func (s *EmailChange) RequestEmailChange(ctx context.Context, AssetToken, newEmail string) error {
+	const op = "service.RequestEmailChange"
+	log := logger.FromContext(ctx, s.Log)
+
+	newEmail, err := s.Identity.Email(newEmail)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get claims from token", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get user by login within transaction: %w", op, err)
+		}
+		if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = account.CheckFull(s.Cfg.Account, user); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = revocation.CheckSession(ctx, s.Storage, claims, user.Id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if user.Email == newEmail {
+			return fmt.Errorf("%s: %w", op, ErrSameEmail)
+		}
+
+		// Confirmation checks again: the address may be taken in between.
+		_, err = s.Storage.GetUserByEmail(ctx, newEmail)
+		if err == nil {
+			return fmt.Errorf("%s: %w", op, domain.ErrEmailTaken)
+		}
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			return fmt.Errorf("%s: failed to get user by email within transaction: %w", op, err)
+		}
+
+		expiresAt := time.Now().Add(s.Cfg.TokenTTL.EmailChange)
+		if err = s.Storage.CreateEmailChange(ctx, user.Id, newEmail, expiresAt); err != nil {
+			return fmt.Errorf("%s: failed to store pending email within transaction: %w", op, err)
+		}
+
+		payload, err := json.Marshal(ChangeEmail{UserId: user.Id, Login: user.Login, OldEmail: user.Email, NewEmail: newEmail})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = s.Storage.EnqueueOutbox(ctx, KindEmailChange, payload); err != nil {
+			return fmt.Errorf("%s: failed to enqueue email change within transaction: %w", op, err)
+		}
+
+		log.Info("RequestEmailChange method called", slog.String("login", user.Login))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SendEmailChange is the outbox handler for KindEmailChange. It mints both
+// tokens, stores their hashes and mails the confirmation link to the new
+// address and the cancel link to the old one. A change that was replaced
+// or cancelled in the meantime is skipped.
+func (s *EmailChange) SendEmailChange(ctx context.Context, payload []byte) error {
+	const op = "service.SendEmailChange"
+	log := logger.FromContext(ctx, s.Log)
+
+	var msg ChangeEmail
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	confirmToken, cancelToken := rand.Text(), rand.Text()
+	ok, err := s.Storage.SetEmailChangeTokens(ctx, msg.UserId, msg.NewEmail, hashToken(confirmToken), hashToken(cancelToken))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		log.Info("email change is no longer pending", slog.Int64("user_id", msg.UserId))
+		return nil
+	}
+
+	confirmLink, err := mailer.Link(s.Cfg.Mail.BaseURL, "confirm-email", confirmToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	cancelLink, err := mailer.Link(s.Cfg.Mail.BaseURL, "cancel-email-change", cancelToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	confirmMail, err := mailer.EmailChange(msg.NewEmail, msg.Login, confirmLink)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	notice, err := mailer.EmailChangeNotice(msg.OldEmail, msg.Login, msg.NewEmail, cancelLink)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The notice goes first: the old address must hear about a change
+	// before it can be confirmed.
+	if err = s.Mailer.Send(ctx, notice); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = s.Mailer.Send(ctx, confirmMail); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email change emails sent", slog.Int64("user_id", msg.UserId))
+	return nil
+}
+
+// ConfirmEmailChange applies a pending change and drops the password reset
+// links already mailed to the old address. If another account took the
+// address since the request, auth_email_key rejects the update and
+// domain.ErrEmailTaken is returned; the pending change then stays until it
+// expires or is replaced.
+func (s *EmailChange) ConfirmEmailChange(ctx context.Context, token string) error {
+	const op = "service.ConfirmEmailChange"
+	log := logger.FromContext(ctx, s.Log)
+
+	err := s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		userId, newEmail, ok, err := s.Storage.ConsumeEmailChange(ctx, hashToken(token))
+		if err != nil {
+			return fmt.Errorf("%s: failed to consume email change token within transaction: %w", op, err)
+		}
+		if !ok {
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailChangeToken)
+		}
+
+		user, err := s.Storage.GetUserById(ctx, userId)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get user by id within transaction: %w", op, err)
+		}
+
+		user.Email = newEmail
+		if err = s.Storage.UpdateEmail(ctx, user); err != nil {
+			return fmt.Errorf("%s: failed to update email within transaction: %w", op, err)
+		}
+		if err = s.Storage.DeletePasswordResets(ctx, user.Id); err != nil {
+			return fmt.Errorf("%s: failed to delete password resets within transaction: %w", op, err)
+		}
+
+		log.Info("ConfirmEmailChange method called", slog.String("login", user.Login))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CancelEmailChange drops a pending change using the link sent to the old address.
+func (s *EmailChange) CancelEmailChange(ctx context.Context, token string) error {
+	const op = "service.CancelEmailChange"
+	log := logger.FromContext(ctx, s.Log)
+
+	ok, err := s.Storage.CancelEmailChange(ctx, hashToken(token))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmailChangeToken)
+	}
+
+	log.Info("CancelEmailChange method called")
+	return nil
+}
+
+func hashToken(token string) string {
+	h := sha256.New()
+	h.Write([]byte(token))
+	return hex.EncodeToString(h.Sum(nil))
+}
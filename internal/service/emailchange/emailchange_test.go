@@ -0,0 +1,115 @@
+package emailchange_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/service/emailchange"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+// fakeEmailChangeRepo implements what ConfirmEmailChange uses; the
+// embedded interface panics on anything else.
+type fakeEmailChangeRepo struct {
+	emailchange.EmailChangeRepo
+
+	user         domain.User
+	confirmHash  string
+	newEmail     string
+	resets       map[string]int64
+	updateErr    error
+	deletedReset bool
+}
+
+func (r *fakeEmailChangeRepo) ConsumeEmailChange(_ context.Context, confirmHash string) (int64, string, bool, error) {
+	if confirmHash != r.confirmHash {
+		return 0, "", false, nil
+	}
+	r.confirmHash = ""
+	return r.user.Id, r.newEmail, true, nil
+}
+
+func (r *fakeEmailChangeRepo) GetUserById(_ context.Context, id int64) (*domain.User, error) {
+	if id != r.user.Id {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakeEmailChangeRepo) UpdateEmail(_ context.Context, user *domain.User) error {
+	if r.updateErr != nil {
+		return r.updateErr
+	}
+	r.user.Email = user.Email
+	return nil
+}
+
+func (r *fakeEmailChangeRepo) DeletePasswordResets(_ context.Context, userId int64) error {
+	for hash, id := range r.resets {
+		if id == userId {
+			delete(r.resets, hash)
+		}
+	}
+	r.deletedReset = true
+	return nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func newEmailChange() (*emailchange.EmailChange, *fakeEmailChangeRepo) {
+	repo := &fakeEmailChangeRepo{
+		user:        domain.User{Id: 1, Login: "test_user", Email: "old@example.com"},
+		confirmHash: hashToken("confirm-token"),
+		newEmail:    "new@example.com",
+		resets:      map[string]int64{hashToken("reset-token"): 1, hashToken("other-user"): 2},
+	}
+	return &emailchange.EmailChange{Storage: repo, TxProvider: fakeTx{}, Log: slogdiscard.NewDiscardLogger()}, repo
+}
+
+func TestConfirmEmailChange_DropsPasswordResets(t *testing.T) {
+	s, repo := newEmailChange()
+
+	require.NoError(t, s.ConfirmEmailChange(context.Background(), "confirm-token"))
+
+	require.Equal(t, "new@example.com", repo.user.Email)
+	require.NotContains(t, repo.resets, hashToken("reset-token"), "links sent to the old address stop working")
+	require.Contains(t, repo.resets, hashToken("other-user"))
+}
+
+func TestConfirmEmailChange_Errors(t *testing.T) {
+	t.Run("unknown token", func(t *testing.T) {
+		s, repo := newEmailChange()
+
+		err := s.ConfirmEmailChange(context.Background(), "wrong-token")
+
+		require.ErrorIs(t, err, emailchange.ErrInvalidEmailChangeToken)
+		require.Equal(t, "old@example.com", repo.user.Email)
+		require.False(t, repo.deletedReset)
+	})
+
+	t.Run("address taken meanwhile", func(t *testing.T) {
+		s, repo := newEmailChange()
+		repo.updateErr = domain.ErrEmailTaken
+
+		err := s.ConfirmEmailChange(context.Background(), "confirm-token")
+
+		require.ErrorIs(t, err, domain.ErrEmailTaken)
+		require.False(t, repo.deletedReset)
+	})
+}
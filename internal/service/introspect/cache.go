@@ -0,0 +1,76 @@
+package introspect
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// sweepThreshold bounds how many entries pile up before expired ones are dropped.
+const sweepThreshold = 10000
+
+// Cache keeps active introspection results for a short time.
+// Tokens are keyed by their SHA-256 so raw tokens are not held in memory.
+// Nothing in an entry tells which revocation would end it, so anything
+// that ends sessions on this instance clears the whole cache.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cacheEntry
+}
+
+type cacheEntry struct {
+	result    *Result
+	expiresAt time.Time
+}
+
+func NewCache() *Cache {
+	return &Cache{entries: make(map[[sha256.Size]byte]cacheEntry)}
+}
+
+func (c *Cache) Get(token string) (*Result, bool) {
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.result, true
+}
+
+func (c *Cache) Put(token string, result *Result, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= sweepThreshold {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= sweepThreshold {
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{result: result, expiresAt: now.Add(ttl)}
+}
+
+// Clear drops every entry.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
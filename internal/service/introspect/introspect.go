@@ -0,0 +1,148 @@
+package introspect
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+)
+
+// Result follows the RFC 7662 introspection response. An inactive token
+// carries nothing but Active=false, so callers learn nothing about why.
+type Result struct {
+	Active    bool
+	Subject   string
+	Username  string
+	Scope     string
+	TokenType string
+	SessionID string
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// ErrUnauthorizedClient means the caller is not a configured client or
+// sent the wrong secret.
+var ErrUnauthorizedClient = errors.New("client is not allowed to introspect tokens")
+
+type Introspect struct {
+	Storage IntrospectRepo
+	Keys    *myjwt.Keys
+	Revoked revocation.Store
+	Cache   *Cache
+	Cfg     *config.Config
+	Log     *slog.Logger
+}
+
+type IntrospectRepo interface {
+	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+}
+
+// Introspect reports whether token is active for the client clientID,
+// which must authenticate with clientSecret. A token is active only while
+// its session exists, and a refresh token only while it is the latest one
+// of its session.
+func (s *Introspect) Introspect(ctx context.Context, clientID, clientSecret, token string) (*Result, error) {
+	const op = "service.Introspect"
+	log := logger.FromContext(ctx, s.Log)
+
+	if !s.authorized(clientID, clientSecret) {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthorizedClient)
+	}
+
+	// Signature, expiry and token use are checked without touching storage.
+	claims, err := myjwt.Introspect(token, s.Keys)
+	if err != nil {
+		log.Debug("token is not active", logger.Err(err))
+		return &Result{}, nil
+	}
+
+	// The revocation store is shared by every instance, so a logout
+	// elsewhere ends a cached answer here too.
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		if errors.Is(err, myjwt.ErrTokenRevoked) {
+			return &Result{}, nil
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if cached, ok := s.Cache.Get(token); ok {
+		return cached, nil
+	}
+
+	user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return &Result{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get user by login: %w", op, err)
+	}
+	if claims.CheckGeneration(user.TokenGeneration) != nil {
+		return &Result{}, nil
+	}
+	if _, err = account.Check(s.Cfg.Account, user); err != nil {
+		return &Result{}, nil
+	}
+
+	session, err := s.Storage.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return &Result{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get session: %w", op, err)
+	}
+	if session.UserId != user.Id {
+		return &Result{}, nil
+	}
+	// A rotated refresh token keeps a valid signature but is spent.
+	if claims.Use == myjwt.TokenRefresh && !equalHash(session.RefreshTokenHash, hashToken(token)) {
+		return &Result{}, nil
+	}
+
+	result := &Result{
+		Active:    true,
+		Subject:   user.Login,
+		Username:  user.Login,
+		Scope:     claims.Scope,
+		TokenType: string(claims.Use),
+		SessionID: claims.SessionID,
+		ID:        claims.ID,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}
+	// Refresh tokens are spent by every rotation, so they are not cached.
+	if claims.Use == myjwt.TokenAccess {
+		s.Cache.Put(token, result, min(s.Cfg.Introspection.CacheTTL, time.Until(claims.ExpiresAt)))
+	}
+
+	return result, nil
+}
+
+// authorized checks the secret in constant time, also for unknown clients.
+func (s *Introspect) authorized(clientID, clientSecret string) bool {
+	want, ok := s.Cfg.Introspection.Clients[clientID]
+	match := equalHash(strings.ToLower(want), hashToken(clientSecret))
+	return ok && clientID != "" && match
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func equalHash(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
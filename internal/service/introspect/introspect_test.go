@@ -0,0 +1,237 @@
+package introspect_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/service/introspect"
+)
+
+const (
+	clientID     = "resource-server"
+	clientSecret = "s3cret"
+)
+
+type fakeIntrospectRepo struct {
+	user     domain.User
+	sessions map[string]domain.Session
+}
+
+func (r *fakeIntrospectRepo) GetUserByLogin(_ context.Context, login string) (*domain.User, error) {
+	if login != r.user.Login {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakeIntrospectRepo) GetSession(_ context.Context, id string) (*domain.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func newIntrospect(t *testing.T) (*introspect.Introspect, *fakeIntrospectRepo) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Access: time.Hour, Refresh: time.Hour},
+		Account:  config.Account{Unverified: account.UnverifiedDeny},
+		Introspection: config.Introspection{
+			Clients: map[string]string{clientID: hashToken(clientSecret)},
+		},
+	}
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+
+	repo := &fakeIntrospectRepo{
+		user:     domain.User{Id: 1, Login: "test_user", Role: "admin", IsActive: true, IsVerified: true},
+		sessions: make(map[string]domain.Session),
+	}
+	return &introspect.Introspect{
+		Storage: repo,
+		Keys:    keys,
+		Revoked: revocation.NewMemory(0, slogdiscard.NewDiscardLogger()),
+		Cache:   introspect.NewCache(),
+		Cfg:     cfg,
+		Log:     slogdiscard.NewDiscardLogger(),
+	}, repo
+}
+
+// signIn issues a token pair for a new session of the repo user.
+func signIn(t *testing.T, svc *introspect.Introspect, repo *fakeIntrospectRepo, scope string) (access, refresh, sessionID string) {
+	t.Helper()
+
+	sessionID = myjwt.NewSessionID()
+	claims := myjwt.Claims{Login: repo.user.Login, SessionID: sessionID, Scope: scope}
+	access, err := myjwt.CreateAccessToken(svc.Cfg, svc.Keys, svc.Log, claims)
+	require.NoError(t, err)
+	refresh, err = myjwt.CreateRefreshToken(svc.Cfg, svc.Keys, svc.Log, claims)
+	require.NoError(t, err)
+
+	repo.sessions[sessionID] = domain.Session{Id: sessionID, UserId: repo.user.Id, RefreshTokenHash: hashToken(refresh)}
+	return access, refresh, sessionID
+}
+
+func TestIntrospect_Session(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("active access and refresh token", func(t *testing.T) {
+		svc, repo := newIntrospect(t)
+		access, refresh, _ := signIn(t, svc, repo, "")
+
+		for _, token := range []string{access, refresh} {
+			result, err := svc.Introspect(ctx, clientID, clientSecret, token)
+			require.NoError(t, err)
+			require.True(t, result.Active)
+			require.Equal(t, "test_user", result.Subject)
+		}
+	})
+
+	t.Run("rotated refresh token", func(t *testing.T) {
+		svc, repo := newIntrospect(t)
+		_, refresh, sessionID := signIn(t, svc, repo, "")
+
+		session := repo.sessions[sessionID]
+		session.RefreshTokenHash = hashToken("newer_refresh_token")
+		repo.sessions[sessionID] = session
+
+		result, err := svc.Introspect(ctx, clientID, clientSecret, refresh)
+		require.NoError(t, err)
+		require.False(t, result.Active)
+	})
+
+	t.Run("session ended by log out", func(t *testing.T) {
+		svc, repo := newIntrospect(t)
+		access, refresh, sessionID := signIn(t, svc, repo, "")
+		delete(repo.sessions, sessionID)
+
+		for _, token := range []string{access, refresh} {
+			result, err := svc.Introspect(ctx, clientID, clientSecret, token)
+			require.NoError(t, err)
+			require.False(t, result.Active)
+		}
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		svc, repo := newIntrospect(t)
+		access, _, sessionID := signIn(t, svc, repo, "")
+		session := repo.sessions[sessionID]
+		session.UserId = 2
+		repo.sessions[sessionID] = session
+
+		result, err := svc.Introspect(ctx, clientID, clientSecret, access)
+		require.NoError(t, err)
+		require.False(t, result.Active)
+	})
+}
+
+func TestIntrospect_CacheAfterRevocation(t *testing.T) {
+	ctx := context.Background()
+
+	newCached := func(t *testing.T) (*introspect.Introspect, *fakeIntrospectRepo) {
+		svc, repo := newIntrospect(t)
+		svc.Cfg.Introspection.CacheTTL = time.Minute
+		return svc, repo
+	}
+	introspectActive := func(t *testing.T, svc *introspect.Introspect, token string) bool {
+		t.Helper()
+		result, err := svc.Introspect(ctx, clientID, clientSecret, token)
+		require.NoError(t, err)
+		return result.Active
+	}
+
+	t.Run("revoked token id", func(t *testing.T) {
+		svc, repo := newCached(t)
+		access, _, _ := signIn(t, svc, repo, "")
+		require.True(t, introspectActive(t, svc, access))
+
+		claims, err := myjwt.GetClaims(access, svc.Keys, myjwt.TokenAccess)
+		require.NoError(t, err)
+		require.NoError(t, revocation.Revoke(ctx, svc.Revoked, claims))
+
+		require.False(t, introspectActive(t, svc, access), "a cached answer does not outlive a logout")
+	})
+
+	t.Run("revoked session", func(t *testing.T) {
+		svc, repo := newCached(t)
+		access, _, sessionID := signIn(t, svc, repo, "")
+		require.True(t, introspectActive(t, svc, access))
+
+		delete(repo.sessions, sessionID)
+		require.True(t, introspectActive(t, svc, access), "the answer is cached")
+
+		svc.Cache.Clear()
+		require.False(t, introspectActive(t, svc, access))
+	})
+
+	t.Run("refresh token is not cached", func(t *testing.T) {
+		svc, repo := newCached(t)
+		_, refresh, sessionID := signIn(t, svc, repo, "")
+		require.True(t, introspectActive(t, svc, refresh))
+
+		session := repo.sessions[sessionID]
+		session.RefreshTokenHash = hashToken("newer_refresh_token")
+		repo.sessions[sessionID] = session
+
+		require.False(t, introspectActive(t, svc, refresh))
+	})
+}
+
+func TestIntrospect_Scope(t *testing.T) {
+	ctx := context.Background()
+	svc, repo := newIntrospect(t)
+
+	access, _, _ := signIn(t, svc, repo, "")
+	result, err := svc.Introspect(ctx, clientID, clientSecret, access)
+	require.NoError(t, err)
+	require.True(t, result.Active)
+	require.Empty(t, result.Scope, "the role is not a scope")
+
+	access, _, _ = signIn(t, svc, repo, account.ScopeUnverified)
+	result, err = svc.Introspect(ctx, clientID, clientSecret, access)
+	require.NoError(t, err)
+	require.Equal(t, account.ScopeUnverified, result.Scope)
+}
+
+func TestIntrospect_ClientAuthentication(t *testing.T) {
+	ctx := context.Background()
+	svc, repo := newIntrospect(t)
+	access, _, _ := signIn(t, svc, repo, "")
+
+	tests := []struct {
+		name   string
+		id     string
+		secret string
+	}{
+		{name: "wrong secret", id: clientID, secret: "wrong"},
+		{name: "unknown client", id: "someone", secret: clientSecret},
+		{name: "no credentials", id: "", secret: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := svc.Introspect(ctx, tc.id, tc.secret, access)
+			require.ErrorIs(t, err, introspect.ErrUnauthorizedClient)
+			require.Nil(t, result)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package jwks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+)
+
+const adminRole = "admin"
+
+var ErrForbidden = errors.New("admin role required")
+
+type JWKS struct {
+	Storage JWKSRepo
+	Keys    *myjwt.Keys
+	Revoked revocation.Store
+	Log     *slog.Logger
+	Cfg     *config.Config
+	// LoadConfig re-reads the configuration that names the signing keys.
+	LoadConfig func() (*config.Config, error)
+}
+
+type JWKSRepo interface {
+	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+}
+
+func (s *JWKS) PublicKeys(ctx context.Context) myjwt.JWKS {
+	return s.Keys.JWKS()
+}
+
+// Reload re-reads the config and rotates to the signing key it names.
+func (s *JWKS) Reload(ctx context.Context) error {
+	const op = "service.Reload"
+	log := logger.FromContext(ctx, s.Log)
+
+	cfg, err := s.LoadConfig()
+	if err != nil {
+		log.Error("failed to reload config", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	prev := s.Keys.ActiveKeyID()
+	if err = s.Keys.Reload(cfg); err != nil {
+		log.Error("failed to reload signing keys", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("signing keys reloaded", slog.String("previous_kid", prev), slog.String("active_kid", s.Keys.ActiveKeyID()))
+	return nil
+}
+
+func (s *JWKS) RotateSigningKeys(ctx context.Context, AssetToken string) error {
+	const op = "service.RotateSigningKeys"
+	log := logger.FromContext(ctx, s.Log)
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get login from token", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
+	if err != nil {
+		return fmt.Errorf("%s: failed to get user by login: %w", op, err)
+	}
+	if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = user.CheckActive(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.CheckSession(ctx, s.Storage, claims, user.Id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Role != adminRole {
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if err = s.Reload(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("RotateSigningKeys method called", slog.String("login", claims.Login))
+	return nil
+}
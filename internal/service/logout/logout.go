@@ -9,6 +9,7 @@ import (
 	"github.com/Weit145/Auth_golang/internal/domain"
 	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
 	"github.com/Weit145/Auth_golang/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
@@ -17,35 +18,47 @@ type LogOut struct {
 	Storage    LogOutRepo
 	TxProvider storage.TxProvider
 	Log        *slog.Logger
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
 	Cfg        *config.Config
 }
 
 type LogOutRepo interface {
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
-	AuthenticateRepo(ctx context.Context, user *domain.User) error
+	DeleteSession(ctx context.Context, userId int64, id string) error
 }
 
 func (s *LogOut) LogOutUser(ctx context.Context, AssetToken string) error {
 	const op = "service.LogOutUser"
+	log := logger.FromContext(ctx, s.Log)
 
-	login, err := myjwt.GetLogin(AssetToken, s.Cfg.JWT.Secret)
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
 	if err != nil {
-		s.Log.Error("failed to get login from token", slog.String("token", AssetToken), logger.Err(err))
+		log.Error("failed to get login from token", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
-		user, err := s.Storage.GetUserByLogin(ctx, login)
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
 		if err != nil {
 			return fmt.Errorf("%s: failed to get user by login within transaction: %w", op, err)
 		}
+		if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 
-		user.RefreshTokenHash = ""
-
-		if err = s.Storage.AuthenticateRepo(ctx, user); err != nil {
+		if err = s.Storage.DeleteSession(ctx, user.Id, claims.SessionID); err != nil {
 			return fmt.Errorf("%s: failed to logout user within transaction: %w", op, err)
 		}
-		s.Log.Info("LogOut method called", slog.String("Token: ", AssetToken))
+		if err = revocation.Revoke(ctx, s.Revoked, claims); err != nil {
+			return fmt.Errorf("%s: failed to revoke access token within transaction: %w", op, err)
+		}
+		log.Info("LogOut method called", slog.String("jti", claims.ID), slog.String("session_id", claims.SessionID))
 		return nil
 	})
 	if err != nil {
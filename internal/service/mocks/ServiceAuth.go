@@ -6,7 +6,11 @@ import (
 	context "context"
 
 	current "github.com/Weit145/Auth_golang/internal/service/current"
+	introspect "github.com/Weit145/Auth_golang/internal/service/introspect"
+	sessions "github.com/Weit145/Auth_golang/internal/service/sessions"
 	mock "github.com/stretchr/testify/mock"
+
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 )
 
 // ServiceAuth is an autogenerated mock type for the ServiceAuth type
@@ -14,6 +18,59 @@ type ServiceAuth struct {
 	mock.Mock
 }
 
+// CancelEmailChange provides a mock function with given fields: ctx, token
+func (_m *ServiceAuth) CancelEmailChange(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelEmailChange")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ChangePassword provides a mock function with given fields: ctx, AssetToken, oldPassword, newPassword, keepCurrentSession
+func (_m *ServiceAuth) ChangePassword(ctx context.Context, AssetToken string, oldPassword string, newPassword string, keepCurrentSession bool) (string, string, error) {
+	ret := _m.Called(ctx, AssetToken, oldPassword, newPassword, keepCurrentSession)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, bool) (string, string, error)); ok {
+		return rf(ctx, AssetToken, oldPassword, newPassword, keepCurrentSession)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, bool) string); ok {
+		r0 = rf(ctx, AssetToken, oldPassword, newPassword, keepCurrentSession)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, bool) string); ok {
+		r1 = rf(ctx, AssetToken, oldPassword, newPassword, keepCurrentSession)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string, string, string, bool) error); ok {
+		r2 = rf(ctx, AssetToken, oldPassword, newPassword, keepCurrentSession)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // Confirm provides a mock function with given fields: ctx, token
 func (_m *ServiceAuth) Confirm(ctx context.Context, token string) (string, string, error) {
 	ret := _m.Called(ctx, token)
@@ -49,6 +106,24 @@ func (_m *ServiceAuth) Confirm(ctx context.Context, token string) (string, strin
 	return r0, r1, r2
 }
 
+// ConfirmEmailChange provides a mock function with given fields: ctx, token
+func (_m *ServiceAuth) ConfirmEmailChange(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConfirmEmailChange")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateUser provides a mock function with given fields: ctx, login, email, password
 func (_m *ServiceAuth) CreateUser(ctx context.Context, login string, email string, password string) error {
 	ret := _m.Called(ctx, login, email, password)
@@ -97,6 +172,66 @@ func (_m *ServiceAuth) Current(ctx context.Context, AssetToken string) (*current
 	return r0, r1
 }
 
+// Introspect provides a mock function with given fields: ctx, clientID, clientSecret, token
+func (_m *ServiceAuth) Introspect(ctx context.Context, clientID string, clientSecret string, token string) (*introspect.Result, error) {
+	ret := _m.Called(ctx, clientID, clientSecret, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Introspect")
+	}
+
+	var r0 *introspect.Result
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (*introspect.Result, error)); ok {
+		return rf(ctx, clientID, clientSecret, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *introspect.Result); ok {
+		r0 = rf(ctx, clientID, clientSecret, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*introspect.Result)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, clientID, clientSecret, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListSessions provides a mock function with given fields: ctx, AssetToken
+func (_m *ServiceAuth) ListSessions(ctx context.Context, AssetToken string) ([]sessions.Session, error) {
+	ret := _m.Called(ctx, AssetToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListSessions")
+	}
+
+	var r0 []sessions.Session
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]sessions.Session, error)); ok {
+		return rf(ctx, AssetToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []sessions.Session); ok {
+		r0 = rf(ctx, AssetToken)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]sessions.Session)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, AssetToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // LogOutUser provides a mock function with given fields: ctx, AssetToken
 func (_m *ServiceAuth) LogOutUser(ctx context.Context, AssetToken string) error {
 	ret := _m.Called(ctx, AssetToken)
@@ -150,8 +285,26 @@ func (_m *ServiceAuth) LoginUser(ctx context.Context, login string, password str
 	return r0, r1, r2
 }
 
+// PublicKeys provides a mock function with given fields: ctx
+func (_m *ServiceAuth) PublicKeys(ctx context.Context) myjwt.JWKS {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PublicKeys")
+	}
+
+	var r0 myjwt.JWKS
+	if rf, ok := ret.Get(0).(func(context.Context) myjwt.JWKS); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(myjwt.JWKS)
+	}
+
+	return r0
+}
+
 // Refresh provides a mock function with given fields: ctx, RefreshToken
-func (_m *ServiceAuth) Refresh(ctx context.Context, RefreshToken string) (string, error) {
+func (_m *ServiceAuth) Refresh(ctx context.Context, RefreshToken string) (string, string, error) {
 	ret := _m.Called(ctx, RefreshToken)
 
 	if len(ret) == 0 {
@@ -159,8 +312,9 @@ func (_m *ServiceAuth) Refresh(ctx context.Context, RefreshToken string) (string
 	}
 
 	var r0 string
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, string, error)); ok {
 		return rf(ctx, RefreshToken)
 	}
 	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
@@ -169,13 +323,180 @@ func (_m *ServiceAuth) Refresh(ctx context.Context, RefreshToken string) (string
 		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+	if rf, ok := ret.Get(1).(func(context.Context, string) string); ok {
 		r1 = rf(ctx, RefreshToken)
 	} else {
-		r1 = ret.Error(1)
+		r1 = ret.Get(1).(string)
 	}
 
-	return r0, r1
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, RefreshToken)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RequestEmailChange provides a mock function with given fields: ctx, AssetToken, newEmail
+func (_m *ServiceAuth) RequestEmailChange(ctx context.Context, AssetToken string, newEmail string) error {
+	ret := _m.Called(ctx, AssetToken, newEmail)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequestEmailChange")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, AssetToken, newEmail)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RequestPasswordReset provides a mock function with given fields: ctx, email
+func (_m *ServiceAuth) RequestPasswordReset(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequestPasswordReset")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResendVerification provides a mock function with given fields: ctx, email
+func (_m *ServiceAuth) ResendVerification(ctx context.Context, email string) error {
+	ret := _m.Called(ctx, email)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResendVerification")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResetPassword provides a mock function with given fields: ctx, token, newPassword
+func (_m *ServiceAuth) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	ret := _m.Called(ctx, token, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ResetPassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, token, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeOtherSessions provides a mock function with given fields: ctx, AssetToken
+func (_m *ServiceAuth) RevokeOtherSessions(ctx context.Context, AssetToken string) (string, string, error) {
+	ret := _m.Called(ctx, AssetToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeOtherSessions")
+	}
+
+	var r0 string
+	var r1 string
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, string, error)); ok {
+		return rf(ctx, AssetToken)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, AssetToken)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) string); ok {
+		r1 = rf(ctx, AssetToken)
+	} else {
+		r1 = ret.Get(1).(string)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = rf(ctx, AssetToken)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RevokeSession provides a mock function with given fields: ctx, AssetToken, sessionID
+func (_m *ServiceAuth) RevokeSession(ctx context.Context, AssetToken string, sessionID string) error {
+	ret := _m.Called(ctx, AssetToken, sessionID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeSession")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, AssetToken, sessionID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RotateSigningKeys provides a mock function with given fields: ctx, AssetToken
+func (_m *ServiceAuth) RotateSigningKeys(ctx context.Context, AssetToken string) error {
+	ret := _m.Called(ctx, AssetToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RotateSigningKeys")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, AssetToken)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnlockAccount provides a mock function with given fields: ctx, AssetToken, login, ip
+func (_m *ServiceAuth) UnlockAccount(ctx context.Context, AssetToken string, login string, ip string) error {
+	ret := _m.Called(ctx, AssetToken, login, ip)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UnlockAccount")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, AssetToken, login, ip)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // NewServiceAuth creates a new instance of ServiceAuth. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -0,0 +1,218 @@
+package passwordreset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	"github.com/Weit145/Auth_golang/internal/lib/cooldown"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// KindPasswordResetEmail outbox messages carry a ResetEmail.
+const KindPasswordResetEmail = "password_reset_email"
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+type ResetEmail struct {
+	UserId int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Login  string `json:"login"`
+}
+
+type PasswordReset struct {
+	Storage    PasswordResetRepo
+	TxProvider storage.TxProvider
+	Hasher     *hasher.Hasher
+	Policy     *passwordpolicy.Policy
+	Mailer     mailer.Mailer
+	Lockout    *lockout.Guard
+	Cfg        *config.Config
+	Log        *slog.Logger
+	// ByEmail and ByIP throttle RequestPasswordReset.
+	ByEmail *cooldown.Cooldown
+	ByIP    *cooldown.Cooldown
+}
+
+type PasswordResetRepo interface {
+	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetUserById(ctx context.Context, id int64) (*domain.User, error)
+	EnqueueOutbox(ctx context.Context, kind string, payload []byte) error
+	CreatePasswordReset(ctx context.Context, userId int64, tokenHash string, expiresAt time.Time) error
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (int64, bool, error)
+	DeletePasswordResets(ctx context.Context, userId int64) error
+	UpdatePasswordHash(ctx context.Context, user *domain.User) error
+	DeleteUserSessions(ctx context.Context, userId int64) error
+	IncrementTokenGeneration(ctx context.Context, user *domain.User) error
+}
+
+// RequestPasswordReset queues a reset email. Like ResendVerification it
+// succeeds for unknown addresses too, so it cannot be used to probe accounts.
+func (s *PasswordReset) RequestPasswordReset(ctx context.Context, email string) error {
+	const op = "service.RequestPasswordReset"
+	log := logger.FromContext(ctx, s.Log)
+
+	// Only normalized, not validated, so addresses stored under older
+	// rules are still found; unknown ones succeed silently anyway.
+	email = identity.NormalizeEmail(email)
+
+	client := clientinfo.FromContext(ctx)
+	allowed, err := s.ByIP.Allow(ctx, client.IP)
+	if err == nil && allowed {
+		allowed, err = s.ByEmail.Allow(ctx, email)
+	}
+	if err != nil {
+		log.Error("failed to check cooldown", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !allowed {
+		return fmt.Errorf("%s: %w", op, cooldown.ErrTooManyRequests)
+	}
+
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.Storage.GetUserByEmail(ctx, email)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: failed to get user by email within transaction: %w", op, err)
+		}
+
+		payload, err := json.Marshal(ResetEmail{UserId: user.Id, Email: user.Email, Login: user.Login})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = s.Storage.EnqueueOutbox(ctx, KindPasswordResetEmail, payload); err != nil {
+			return fmt.Errorf("%s: failed to enqueue reset email within transaction: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to request password reset", logger.Err(err))
+		return err
+	}
+
+	log.Info("RequestPasswordReset method called", slog.String("ip", client.IP))
+	return nil
+}
+
+// SendResetEmail is the outbox handler for KindPasswordResetEmail.
+// Only the token hash is stored; the token itself exists in the email alone.
+func (s *PasswordReset) SendResetEmail(ctx context.Context, payload []byte) error {
+	const op = "service.SendResetEmail"
+	log := logger.FromContext(ctx, s.Log)
+
+	var msg ResetEmail
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	token := rand.Text()
+	expiresAt := time.Now().Add(s.Cfg.TokenTTL.PasswordReset)
+	if err := s.Storage.CreatePasswordReset(ctx, msg.UserId, hashToken(token), expiresAt); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	link, err := mailer.Link(s.Cfg.Mail.BaseURL, "reset-password", token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	mail, err := mailer.PasswordReset(msg.Email, msg.Login, link)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = s.Mailer.Send(ctx, mail); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset email sent", slog.Int64("user_id", msg.UserId))
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token. Every session and
+// every token issued before the reset stops working. Deactivated accounts
+// cannot be reset. A reset proves control of the email address, so it also
+// lifts a brute-force lock on the login.
+func (s *PasswordReset) ResetPassword(ctx context.Context, token, newPassword string) error {
+	const op = "service.ResetPassword"
+	log := logger.FromContext(ctx, s.Log)
+
+	var login string
+	err := s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		userId, ok, err := s.Storage.ConsumePasswordReset(ctx, hashToken(token))
+		if err != nil {
+			return fmt.Errorf("%s: failed to consume reset token within transaction: %w", op, err)
+		}
+		if !ok {
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		user, err := s.Storage.GetUserById(ctx, userId)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get user by id within transaction: %w", op, err)
+		}
+		if err = user.CheckActive(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		// A rejected password rolls back, so the token can be used again.
+		if err = s.Policy.Check(newPassword, user.Login, user.Email); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		user.PasswordHash, err = s.Hasher.Hash(newPassword)
+		if err != nil {
+			log.Error("failed to generate password hash", logger.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = s.Storage.UpdatePasswordHash(ctx, user); err != nil {
+			return fmt.Errorf("%s: failed to update password within transaction: %w", op, err)
+		}
+		if err = s.Storage.DeleteUserSessions(ctx, user.Id); err != nil {
+			return fmt.Errorf("%s: failed to delete sessions within transaction: %w", op, err)
+		}
+		if err = s.Storage.IncrementTokenGeneration(ctx, user); err != nil {
+			return fmt.Errorf("%s: failed to bump token generation within transaction: %w", op, err)
+		}
+		if err = s.Storage.DeletePasswordResets(ctx, user.Id); err != nil {
+			return fmt.Errorf("%s: failed to delete reset tokens within transaction: %w", op, err)
+		}
+
+		login = user.Login
+		log.Info("ResetPassword method called", slog.String("login", user.Login))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = s.Lockout.Unlock(ctx, login, ""); err != nil {
+		log.Error("failed to clear failed logins", logger.Err(err))
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	h := sha256.New()
+	h.Write([]byte(token))
+	return hex.EncodeToString(h.Sum(nil))
+}
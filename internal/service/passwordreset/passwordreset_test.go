@@ -0,0 +1,226 @@
+package passwordreset_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/service/passwordreset"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+type reset struct {
+	userId    int64
+	expiresAt time.Time
+	used      bool
+}
+
+// fakePasswordResetRepo implements what ResetPassword uses; the embedded
+// interface panics on anything else.
+type fakePasswordResetRepo struct {
+	passwordreset.PasswordResetRepo
+
+	user            domain.User
+	resets          map[string]reset
+	sessionsDeleted bool
+}
+
+func (r *fakePasswordResetRepo) ConsumePasswordReset(_ context.Context, tokenHash string) (int64, bool, error) {
+	rs, ok := r.resets[tokenHash]
+	if !ok || rs.used || !time.Now().Before(rs.expiresAt) {
+		return 0, false, nil
+	}
+	rs.used = true
+	r.resets[tokenHash] = rs
+	return rs.userId, true, nil
+}
+
+func (r *fakePasswordResetRepo) GetUserById(_ context.Context, id int64) (*domain.User, error) {
+	if id != r.user.Id {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakePasswordResetRepo) UpdatePasswordHash(_ context.Context, user *domain.User) error {
+	r.user.PasswordHash = user.PasswordHash
+	return nil
+}
+
+func (r *fakePasswordResetRepo) DeleteUserSessions(context.Context, int64) error {
+	r.sessionsDeleted = true
+	return nil
+}
+
+func (r *fakePasswordResetRepo) IncrementTokenGeneration(_ context.Context, user *domain.User) error {
+	r.user.TokenGeneration++
+	user.TokenGeneration = r.user.TokenGeneration
+	return nil
+}
+
+func (r *fakePasswordResetRepo) DeletePasswordResets(_ context.Context, userId int64) error {
+	for hash, rs := range r.resets {
+		if rs.userId == userId {
+			delete(r.resets, hash)
+		}
+	}
+	return nil
+}
+
+type fakeLockoutStore struct {
+	attempts map[string]domain.LoginAttempts
+}
+
+func (s *fakeLockoutStore) LoginAttemptsForUpdate(_ context.Context, key string) (*domain.LoginAttempts, error) {
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = domain.LoginAttempts{Key: key, LastFailureAt: time.Now()}
+	}
+	return &attempts, nil
+}
+
+func (s *fakeLockoutStore) SaveLoginAttempts(_ context.Context, attempts *domain.LoginAttempts) error {
+	s.attempts[attempts.Key] = *attempts
+	return nil
+}
+
+func (s *fakeLockoutStore) ClearLoginFailures(_ context.Context, keys []string) error {
+	for _, key := range keys {
+		delete(s.attempts, key)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+func newPasswordReset(t *testing.T) (*passwordreset.PasswordReset, *fakePasswordResetRepo) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Password: config.Password{
+			Algorithm: "bcrypt",
+			Bcrypt:    config.Bcrypt{Cost: 4},
+			Policy:    config.Policy{MinLength: 8, MaxLength: 72},
+		},
+		Lockout: config.Lockout{LoginThreshold: 3, IPThreshold: 20, BaseDelay: time.Second, MaxDelay: time.Minute, Window: time.Hour},
+	}
+
+	h, err := hasher.New(cfg)
+	require.NoError(t, err)
+	policy, err := passwordpolicy.New(cfg)
+	require.NoError(t, err)
+
+	repo := &fakePasswordResetRepo{
+		user: domain.User{Id: 1, Login: "test_user", Email: "test@example.com", IsActive: true, IsVerified: true},
+		resets: map[string]reset{
+			hashToken("valid-token"):   {userId: 1, expiresAt: time.Now().Add(time.Hour)},
+			hashToken("expired-token"): {userId: 1, expiresAt: time.Now().Add(-time.Minute)},
+		},
+	}
+	repo.user.PasswordHash, err = h.Hash("password123")
+	require.NoError(t, err)
+
+	return &passwordreset.PasswordReset{
+		Storage:    repo,
+		TxProvider: fakeTx{},
+		Hasher:     h,
+		Policy:     policy,
+		Lockout: &lockout.Guard{
+			Store:      &fakeLockoutStore{attempts: make(map[string]domain.LoginAttempts)},
+			TxProvider: fakeTx{},
+			Cfg:        cfg.Lockout,
+		},
+		Cfg: cfg,
+		Log: slogdiscard.NewDiscardLogger(),
+	}, repo
+}
+
+func TestResetPassword(t *testing.T) {
+	s, repo := newPasswordReset(t)
+	ctx := context.Background()
+
+	err := s.ResetPassword(ctx, "valid-token", "Tr0ub4dor&3xyz")
+	require.NoError(t, err)
+	require.NoError(t, s.Hasher.Verify(repo.user.PasswordHash, "Tr0ub4dor&3xyz"))
+	require.True(t, repo.sessionsDeleted)
+	require.Equal(t, int64(1), repo.user.TokenGeneration, "tokens issued before the reset stop working")
+	require.Empty(t, repo.resets, "other reset tokens of the user are dropped")
+}
+
+func TestResetPassword_SingleUse(t *testing.T) {
+	s, repo := newPasswordReset(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.ResetPassword(ctx, "valid-token", "Tr0ub4dor&3xyz"))
+	hash := repo.user.PasswordHash
+
+	err := s.ResetPassword(ctx, "valid-token", "An0ther&Passw0rd")
+	require.ErrorIs(t, err, passwordreset.ErrInvalidResetToken)
+	require.Equal(t, hash, repo.user.PasswordHash)
+}
+
+func TestResetPassword_InvalidToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "expired", token: "expired-token"},
+		{name: "unknown", token: "unknown-token"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, repo := newPasswordReset(t)
+			hash := repo.user.PasswordHash
+
+			err := s.ResetPassword(context.Background(), tc.token, "Tr0ub4dor&3xyz")
+			require.ErrorIs(t, err, passwordreset.ErrInvalidResetToken)
+			require.Equal(t, hash, repo.user.PasswordHash)
+			require.False(t, repo.sessionsDeleted)
+			require.Zero(t, repo.user.TokenGeneration)
+		})
+	}
+}
+
+func TestResetPassword_Inactive(t *testing.T) {
+	s, repo := newPasswordReset(t)
+	repo.user.IsActive = false
+	hash := repo.user.PasswordHash
+
+	err := s.ResetPassword(context.Background(), "valid-token", "Tr0ub4dor&3xyz")
+	require.ErrorIs(t, err, domain.ErrInactive)
+	require.Equal(t, hash, repo.user.PasswordHash)
+}
+
+func TestResetPassword_ClearsLockout(t *testing.T) {
+	s, _ := newPasswordReset(t)
+	ctx := context.Background()
+
+	for range s.Cfg.Lockout.LoginThreshold {
+		require.NoError(t, s.Lockout.Attempt(ctx, "test_user", ""))
+	}
+	require.ErrorIs(t, s.Lockout.Attempt(ctx, "test_user", ""), lockout.ErrLocked)
+
+	require.NoError(t, s.ResetPassword(ctx, "valid-token", "Tr0ub4dor&3xyz"))
+	require.NoError(t, s.Lockout.Attempt(ctx, "test_user", ""))
+}
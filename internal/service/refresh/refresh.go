@@ -4,13 +4,17 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
 
 	"github.com/Weit145/Auth_golang/internal/config"
 	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
 	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
 	"github.com/Weit145/Auth_golang/internal/storage"
 	"github.com/jackc/pgx/v5"
 )
@@ -19,46 +23,112 @@ type Refresh struct {
 	Storage    RefreshRepo
 	TxProvider storage.TxProvider
 	Log        *slog.Logger
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
 	Cfg        *config.Config
 }
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
 type RefreshRepo interface {
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	RotateSession(ctx context.Context, session *domain.Session, oldHash string) (bool, error)
+	DeleteSession(ctx context.Context, userId int64, id string) error
 }
 
-func (s *Refresh) Refresh(ctx context.Context, RefreshToken string) (newRefreshToken string, err error) {
+// Refresh exchanges a refresh token for a new access and refresh token pair.
+// The presented token stops being valid; replaying it later revokes its session.
+func (s *Refresh) Refresh(ctx context.Context, RefreshToken string) (accessToken, refreshToken string, err error) {
 	const op = "service.Refresh"
+	log := logger.FromContext(ctx, s.Log)
 
-	login, err := myjwt.GetLogin(RefreshToken, s.Cfg.JWT.Secret)
+	claims, err := myjwt.GetClaims(RefreshToken, s.Keys, myjwt.TokenRefresh)
 	if err != nil {
-		s.Log.Error("failed to get login from token", slog.String("token", RefreshToken), logger.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to get claims from token", logger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
+	var reused bool
 	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
-		user, err := s.Storage.GetUserByLogin(ctx, login)
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
 		if err != nil {
 			return fmt.Errorf("%s: failed to get user by login within transaction: %w", op, err)
 		}
+		if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+			return fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+		// The scope follows the account: verifying the email lifts it on
+		// the next refresh.
+		scope, err := account.Check(s.Cfg.Account, user)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		session, err := s.Storage.GetSession(ctx, claims.SessionID)
+		if err != nil || session.UserId != user.Id {
+			return fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
 
 		h := sha256.New()
 		h.Write([]byte(RefreshToken))
 		check := hex.EncodeToString(h.Sum(nil))
 
-		if user.RefreshTokenHash != check {
-			return fmt.Errorf("%s: failed refreshTokenHash to DB", op)
+		if session.RefreshTokenHash != check {
+			// The token belongs to a live session but was already rotated away.
+			if err = s.Storage.DeleteSession(ctx, user.Id, session.Id); err != nil {
+				return fmt.Errorf("%s: failed to revoke session within transaction: %w", op, err)
+			}
+			reused = true
+			return nil
 		}
 
-		newRefreshToken, err = myjwt.CreateLoginJWT(s.Cfg, s.Log, user.Login)
+		tokenClaims := myjwt.Claims{Login: user.Login, SessionID: session.Id, Generation: user.TokenGeneration, Scope: scope}
+		refreshToken, err = myjwt.CreateRefreshToken(s.Cfg, s.Keys, log, tokenClaims)
 		if err != nil {
-			return fmt.Errorf("%s: failed to create login JWT: %w", op, err)
+			return fmt.Errorf("%s: failed to create refresh JWT: %w", op, err)
 		}
-		s.Log.Info("Refresh method called", slog.String("RefreshToken: ", RefreshToken))
+
+		accessToken, err = myjwt.CreateAccessToken(s.Cfg, s.Keys, log, tokenClaims)
+		if err != nil {
+			return fmt.Errorf("%s: failed to create access JWT: %w", op, err)
+		}
+
+		h = sha256.New()
+		h.Write([]byte(refreshToken))
+		session.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
+
+		client := clientinfo.FromContext(ctx)
+		session.UserAgent = client.UserAgent
+		session.IP = client.IP
+
+		rotated, err := s.Storage.RotateSession(ctx, session, check)
+		if err != nil {
+			return fmt.Errorf("%s: failed to rotate refresh token within transaction: %w", op, err)
+		}
+		if !rotated {
+			return fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+
+		log.Info("Refresh method called", slog.String("jti", claims.ID), slog.String("session_id", claims.SessionID))
 		return nil
 	})
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	if reused {
+		log.Warn("refresh token reuse detected, session revoked", slog.String("login", claims.Login), slog.String("session_id", claims.SessionID))
+		return "", "", fmt.Errorf("%s: %w", op, ErrRefreshTokenReused)
 	}
 
-	return newRefreshToken, nil
+	return accessToken, refreshToken, nil
 }
@@ -0,0 +1,204 @@
+package refresh_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/service/refresh"
+	"github.com/jackc/pgx/v5"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+type fakeRefreshRepo struct {
+	user    domain.User
+	session domain.Session
+}
+
+func (r *fakeRefreshRepo) GetUserByLogin(_ context.Context, login string) (*domain.User, error) {
+	if login != r.user.Login {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakeRefreshRepo) GetSession(_ context.Context, id string) (*domain.Session, error) {
+	if id != r.session.Id {
+		return nil, pgx.ErrNoRows
+	}
+	session := r.session
+	return &session, nil
+}
+
+func (r *fakeRefreshRepo) RotateSession(_ context.Context, session *domain.Session, oldHash string) (bool, error) {
+	if r.session.RefreshTokenHash != oldHash {
+		return false, nil
+	}
+	r.session = *session
+	return true, nil
+}
+
+func (r *fakeRefreshRepo) DeleteSession(context.Context, int64, string) error {
+	r.session = domain.Session{}
+	return nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// newRefresh signs in user with a token of scope and returns its refresh token.
+func newRefresh(t *testing.T, unverified, scope string, user domain.User) (refresh.Refresh, string) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Access: time.Hour, Refresh: time.Hour},
+		Account:  config.Account{Unverified: unverified},
+	}
+	log := slogdiscard.NewDiscardLogger()
+
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+
+	sessionID := myjwt.NewSessionID()
+	token, err := myjwt.CreateRefreshToken(cfg, keys, log, myjwt.Claims{
+		Login:      user.Login,
+		SessionID:  sessionID,
+		Generation: user.TokenGeneration,
+		Scope:      scope,
+	})
+	require.NoError(t, err)
+
+	repo := &fakeRefreshRepo{
+		user:    user,
+		session: domain.Session{Id: sessionID, UserId: user.Id, RefreshTokenHash: hashToken(token)},
+	}
+	return refresh.Refresh{
+		Storage:    repo,
+		TxProvider: fakeTx{},
+		Log:        log,
+		Keys:       keys,
+		Revoked:    revocation.NewMemory(0, slogdiscard.NewDiscardLogger()),
+		Cfg:        cfg,
+	}, token
+}
+
+func TestRefresh_AccountState(t *testing.T) {
+	tests := []struct {
+		name          string
+		unverified    string
+		tokenScope    string
+		user          domain.User
+		expectedErr   error
+		expectedScope string
+	}{
+		{
+			name:       "verified active user",
+			unverified: account.UnverifiedDeny,
+			user:       domain.User{Id: 1, Login: "test_user", IsActive: true, IsVerified: true},
+		},
+		{
+			name:        "deactivated since sign in",
+			unverified:  account.UnverifiedAllow,
+			user:        domain.User{Id: 1, Login: "test_user", IsVerified: true},
+			expectedErr: domain.ErrInactive,
+		},
+		{
+			name:        "unverified user denied",
+			unverified:  account.UnverifiedDeny,
+			user:        domain.User{Id: 1, Login: "test_user", IsActive: true},
+			expectedErr: domain.ErrNotVerified,
+		},
+		{
+			name:          "unverified user keeps restricted scope",
+			unverified:    account.UnverifiedRestricted,
+			tokenScope:    account.ScopeUnverified,
+			user:          domain.User{Id: 1, Login: "test_user", IsActive: true},
+			expectedScope: account.ScopeUnverified,
+		},
+		{
+			name:       "verifying lifts restricted scope",
+			unverified: account.UnverifiedRestricted,
+			tokenScope: account.ScopeUnverified,
+			user:       domain.User{Id: 1, Login: "test_user", IsActive: true, IsVerified: true},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, token := newRefresh(t, tc.unverified, tc.tokenScope, tc.user)
+
+			accessToken, refreshToken, err := svc.Refresh(context.Background(), token)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				require.Empty(t, accessToken)
+				require.Empty(t, refreshToken)
+				return
+			}
+			require.NoError(t, err)
+
+			claims, err := myjwt.GetClaims(accessToken, svc.Keys, myjwt.TokenAccess)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedScope, claims.Scope)
+
+			claims, err = myjwt.GetClaims(refreshToken, svc.Keys, myjwt.TokenRefresh)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedScope, claims.Scope)
+		})
+	}
+}
+
+func TestRefresh_LogsNoToken(t *testing.T) {
+	// The service itself must not log tokens; the redacting handler is
+	// only a safety net.
+	for _, redact := range []bool{false, true} {
+		t.Run(fmt.Sprintf("redact=%t", redact), func(t *testing.T) {
+			svc, token := newRefresh(t, account.UnverifiedDeny, "",
+				domain.User{Id: 1, Login: "test_user", Email: "test_user@example.com", IsActive: true, IsVerified: true})
+
+			buf := &bytes.Buffer{}
+			var handler slog.Handler = slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+			if redact {
+				handler = logger.NewRedactHandler(handler, nil)
+			}
+			svc.Log = slog.New(handler)
+
+			accessToken, refreshToken, err := svc.Refresh(context.Background(), token)
+			require.NoError(t, err)
+
+			_, _, err = svc.Refresh(context.Background(), token)
+			require.Error(t, err)
+			_, _, err = svc.Refresh(context.Background(), "not-a-jwt")
+			require.Error(t, err)
+
+			out := buf.String()
+			require.NotEmpty(t, out)
+			for _, tok := range []string{token, accessToken, refreshToken, "not-a-jwt"} {
+				require.NotContains(t, out, tok)
+			}
+			require.NotContains(t, out, "eyJ")
+		})
+	}
+}
@@ -2,48 +2,207 @@ package registration
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 
 	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	"github.com/Weit145/Auth_golang/internal/lib/cooldown"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
 	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
 	"github.com/Weit145/Auth_golang/internal/lib/logger"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+	"github.com/Weit145/Auth_golang/internal/lib/outbox"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
 )
 
+// KindVerificationEmail outbox messages carry a VerificationEmail.
+const KindVerificationEmail = "verification_email"
+
+// EventUserRegistered is sent to the configured webhooks.
+const EventUserRegistered = "user.registered"
+
+type VerificationEmail struct {
+	Email string `json:"email"`
+	Login string `json:"login"`
+}
+
 type Registration struct {
-	Storage RegistrationRepo
-	Log     *slog.Logger
-	Cfg     *config.Config
+	Storage    RegistrationRepo
+	TxProvider storage.TxProvider
+	Hasher     *hasher.Hasher
+	Policy     *passwordpolicy.Policy
+	Identity   *identity.Rules
+	Mailer     mailer.Mailer
+	Log        *slog.Logger
+	Keys       *myjwt.Keys
+	Cfg        *config.Config
+	// ResendByEmail and ResendByIP throttle ResendVerification.
+	ResendByEmail *cooldown.Cooldown
+	ResendByIP    *cooldown.Cooldown
 }
 
 type RegistrationRepo interface {
 	RegistrationRepo(ctx context.Context, login, email, passwordHash string) error
+	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	EnqueueOutbox(ctx context.Context, kind string, payload []byte) error
 }
 
+// CreateUser stores the user and, in the same transaction, the outbox
+// messages that send the confirmation email and notify webhooks.
 func (s *Registration) CreateUser(ctx context.Context, login, email, password string) error {
 	const op = "service.CreateUser"
+	log := logger.FromContext(ctx, s.Log)
+
+	log.Info("CreateUser method called", slog.String("email", email), slog.String("login", login))
+
+	login, err := s.Identity.Login(login)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	email, err = s.Identity.Email(email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.Policy.Check(password, login, email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passwordHash, err := s.Hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to generate password hash", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		if err := s.Storage.RegistrationRepo(ctx, login, email, passwordHash); err != nil {
+			return fmt.Errorf("%s: failed to register user within transaction: %w", op, err)
+		}
+
+		payload, err := json.Marshal(VerificationEmail{Email: email, Login: login})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = s.Storage.EnqueueOutbox(ctx, KindVerificationEmail, payload); err != nil {
+			return fmt.Errorf("%s: failed to enqueue verification email within transaction: %w", op, err)
+		}
+
+		for _, url := range s.Cfg.Outbox.Webhooks {
+			payload, err = json.Marshal(outbox.WebhookEvent{
+				URL:   url,
+				Event: EventUserRegistered,
+				Data:  map[string]string{"login": login, "email": email},
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if err = s.Storage.EnqueueOutbox(ctx, outbox.KindWebhook, payload); err != nil {
+				return fmt.Errorf("%s: failed to enqueue webhook within transaction: %w", op, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to register user", logger.Err(err))
+		return err
+	}
+
+	log.Info("user registered successfully", slog.String("email", email), slog.String("login", login))
+	return nil
+}
+
+// ResendVerification queues a new confirmation email. Callers cannot tell
+// unknown, already verified and pending accounts apart: all of them succeed.
+// Only the cooldowns, which apply to every address alike, are reported.
+func (s *Registration) ResendVerification(ctx context.Context, email string) error {
+	const op = "service.ResendVerification"
+	log := logger.FromContext(ctx, s.Log)
+
+	// Only normalized, not validated, so addresses stored under older
+	// rules are still found; unknown ones succeed silently anyway.
+	email = identity.NormalizeEmail(email)
+
+	client := clientinfo.FromContext(ctx)
+	allowed, err := s.ResendByIP.Allow(ctx, client.IP)
+	if err == nil && allowed {
+		allowed, err = s.ResendByEmail.Allow(ctx, email)
+	}
+	if err != nil {
+		log.Error("failed to check cooldown", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !allowed {
+		return fmt.Errorf("%s: %w", op, cooldown.ErrTooManyRequests)
+	}
 
-	s.Log.Info("CreateUser method called", slog.String("email", email), slog.String("login", login))
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		user, err := s.Storage.GetUserByEmail(ctx, email)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: failed to get user by email within transaction: %w", op, err)
+		}
+		if user.IsVerified {
+			return nil
+		}
+
+		payload, err := json.Marshal(VerificationEmail{Email: user.Email, Login: user.Login})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err = s.Storage.EnqueueOutbox(ctx, KindVerificationEmail, payload); err != nil {
+			return fmt.Errorf("%s: failed to enqueue verification email within transaction: %w", op, err)
+		}
+		return nil
+	})
 	if err != nil {
-		s.Log.Error("failed to generate password hash", logger.Err(err))
+		log.Error("failed to resend verification email", logger.Err(err))
+		return err
+	}
+
+	log.Info("ResendVerification method called", slog.String("ip", client.IP))
+	return nil
+}
+
+// SendVerificationEmail is the outbox handler for KindVerificationEmail.
+// The token is minted at delivery time, so it never sits in the database.
+func (s *Registration) SendVerificationEmail(ctx context.Context, payload []byte) error {
+	const op = "service.SendVerificationEmail"
+	log := logger.FromContext(ctx, s.Log)
+
+	var msg VerificationEmail
+	if err := json.Unmarshal(payload, &msg); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = s.Storage.RegistrationRepo(ctx, login, email, string(passwordHash))
+	token, err := myjwt.CreateVerificationToken(s.Cfg, s.Keys, log, msg.Email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	link, err := mailer.Link(s.Cfg.Mail.BaseURL, "confirm", token)
 	if err != nil {
-		s.Log.Error("failed to register user", logger.Err(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	token, err := myjwt.CreateEmailJWT(s.Cfg, s.Log, email)
+	mail, err := mailer.Verification(msg.Email, msg.Login, link)
 	if err != nil {
-		s.Log.Error("failed to create email JWT", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = s.Mailer.Send(ctx, mail); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	s.Log.Info("user registered successfully", slog.String("email", email), slog.String("login", login))
-	s.Log.Info("Token", slog.String("token", token))
+	log.Info("verification email sent", slog.String("email", msg.Email))
 	return nil
 }
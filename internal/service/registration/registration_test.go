@@ -0,0 +1,59 @@
+package registration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+	"github.com/Weit145/Auth_golang/internal/service/registration"
+)
+
+func TestSendVerificationEmail(t *testing.T) {
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Verification: time.Hour},
+		Mail:     config.Mail{BaseURL: "https://app.example.com"},
+	}
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+	mail := &mailer.Memory{}
+
+	s := &registration.Registration{Mailer: mail, Keys: keys, Cfg: cfg, Log: slogdiscard.NewDiscardLogger()}
+
+	payload, err := json.Marshal(registration.VerificationEmail{Email: "user@example.com", Login: "test_user"})
+	require.NoError(t, err)
+	require.NoError(t, s.SendVerificationEmail(context.Background(), payload))
+
+	sent := mail.Messages()
+	require.Len(t, sent, 1)
+	require.Equal(t, "user@example.com", sent[0].To)
+	require.Contains(t, sent[0].Text, "test_user")
+
+	// The link in the text part carries a token for the same address.
+	start := strings.Index(sent[0].Text, cfg.Mail.BaseURL)
+	require.GreaterOrEqual(t, start, 0)
+	link, err := url.Parse(strings.Fields(sent[0].Text[start:])[0])
+	require.NoError(t, err)
+	require.Equal(t, "/confirm", link.Path)
+
+	claims, err := myjwt.GetVerificationClaims(link.Query().Get("token"), keys)
+	require.NoError(t, err)
+	require.Equal(t, "user@example.com", claims.Email)
+}
+
+func TestSendVerificationEmail_BadPayload(t *testing.T) {
+	mail := &mailer.Memory{}
+	s := &registration.Registration{Mailer: mail, Log: slogdiscard.NewDiscardLogger()}
+
+	require.Error(t, s.SendVerificationEmail(context.Background(), []byte("{")))
+	require.Empty(t, mail.Messages())
+}
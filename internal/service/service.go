@@ -2,15 +2,32 @@ package service
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
 	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/cooldown"
+	"github.com/Weit145/Auth_golang/internal/lib/hasher"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/mailer"
+	"github.com/Weit145/Auth_golang/internal/lib/passwordpolicy"
+	"github.com/Weit145/Auth_golang/internal/lib/ratelimit"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
 	"github.com/Weit145/Auth_golang/internal/service/authenticate"
+	"github.com/Weit145/Auth_golang/internal/service/changepassword"
 	"github.com/Weit145/Auth_golang/internal/service/confirm"
 	"github.com/Weit145/Auth_golang/internal/service/current"
+	"github.com/Weit145/Auth_golang/internal/service/emailchange"
+	"github.com/Weit145/Auth_golang/internal/service/introspect"
+	"github.com/Weit145/Auth_golang/internal/service/jwks"
 	"github.com/Weit145/Auth_golang/internal/service/logout"
+	"github.com/Weit145/Auth_golang/internal/service/passwordreset"
 	"github.com/Weit145/Auth_golang/internal/service/refresh"
 	"github.com/Weit145/Auth_golang/internal/service/registration"
+	"github.com/Weit145/Auth_golang/internal/service/sessions"
+	"github.com/Weit145/Auth_golang/internal/service/unlock"
 	"github.com/Weit145/Auth_golang/internal/storage"
 )
 
@@ -21,6 +38,15 @@ type Service struct {
 	LogOut       logout.LogOut
 	RefreshUser  refresh.Refresh
 	Registration registration.Registration
+	SigningKeys  jwks.JWKS
+	Sessions     sessions.Sessions
+	// Introspector's cache is cleared by every call below that ends
+	// sessions or bumps the token generation.
+	Introspector   introspect.Introspect
+	Reset          passwordreset.PasswordReset
+	PasswordChange changepassword.ChangePassword
+	EmailChange    emailchange.EmailChange
+	Unlock         unlock.Unlock
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.53.5 --name=ServiceAuth
@@ -29,40 +55,145 @@ type ServiceAuth interface {
 	Confirm(ctx context.Context, token string) (string, string, error)
 	Current(ctx context.Context, AssetToken string) (*current.User, error)
 	LogOutUser(ctx context.Context, AssetToken string) error
-	Refresh(ctx context.Context, RefreshToken string) (string, error)
+	Refresh(ctx context.Context, RefreshToken string) (string, string, error)
 	CreateUser(ctx context.Context, login, email, password string) error
+	PublicKeys(ctx context.Context) myjwt.JWKS
+	RotateSigningKeys(ctx context.Context, AssetToken string) error
+	ListSessions(ctx context.Context, AssetToken string) ([]sessions.Session, error)
+	RevokeSession(ctx context.Context, AssetToken, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, AssetToken string) (string, string, error)
+	Introspect(ctx context.Context, clientID, clientSecret, token string) (*introspect.Result, error)
+	ResendVerification(ctx context.Context, email string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	ChangePassword(ctx context.Context, AssetToken, oldPassword, newPassword string, keepCurrentSession bool) (string, string, error)
+	RequestEmailChange(ctx context.Context, AssetToken, newEmail string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	CancelEmailChange(ctx context.Context, token string) error
+	UnlockAccount(ctx context.Context, AssetToken, login, ip string) error
 }
 
-func New(log *slog.Logger, storage storage.Storage, cfg *config.Config) *Service {
+func New(log *slog.Logger, storage storage.Storage, hasher *hasher.Hasher, policy *passwordpolicy.Policy, rules *identity.Rules, keys *myjwt.Keys, revoked revocation.Store, limits ratelimit.Store, mail mailer.Mailer, cfg *config.Config) *Service {
+	guard := &lockout.Guard{Store: storage, TxProvider: storage, Cfg: cfg.Lockout}
+
 	return &Service{
 		Auth: authenticate.Login{
-			Storage: storage,
-			Cfg:     cfg,
-			Log:     log,
+			Storage:    storage,
+			TxProvider: storage,
+			Hasher:     hasher,
+			Lockout:    guard,
+			Keys:       keys,
+			Cfg:        cfg,
+			Log:        log,
 		},
 		ConfirmUser: confirm.Confirm{
-			Storage: storage,
-			Cfg:     cfg,
-			Log:     log,
+			Storage:    storage,
+			TxProvider: storage,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
 		},
 		CurrentUser: current.Current{
-			Storage: storage,
-			Cfg:     cfg,
-			Log:     log,
+			Storage:    storage,
+			TxProvider: storage,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
 		},
 		LogOut: logout.LogOut{
-			Storage: storage,
-			Cfg:     cfg,
-			Log:     log,
+			Storage:    storage,
+			TxProvider: storage,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
 		},
 		RefreshUser: refresh.Refresh{
+			Storage:    storage,
+			TxProvider: storage,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
+		},
+		Registration: registration.Registration{
+			Storage:    storage,
+			TxProvider: storage,
+			Hasher:     hasher,
+			Policy:     policy,
+			Identity:   rules,
+			Mailer:     mail,
+			Keys:       keys,
+			Cfg:        cfg,
+			Log:        log,
+
+			ResendByEmail: cooldown.New(limits, "resend_verification|email", cfg.EmailCooldown.PerEmail),
+			ResendByIP:    cooldown.New(limits, "resend_verification|ip", cfg.EmailCooldown.PerIP),
+		},
+		SigningKeys: jwks.JWKS{
+			Storage:    storage,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
+			LoadConfig: config.Load,
+		},
+		Sessions: sessions.Sessions{
+			Storage:    storage,
+			TxProvider: storage,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
+		},
+		Introspector: introspect.Introspect{
 			Storage: storage,
+			Keys:    keys,
+			Revoked: revoked,
+			Cache:   introspect.NewCache(),
 			Cfg:     cfg,
 			Log:     log,
 		},
-		Registration: registration.Registration{
+		Reset: passwordreset.PasswordReset{
+			Storage:    storage,
+			TxProvider: storage,
+			Hasher:     hasher,
+			Policy:     policy,
+			Mailer:     mail,
+			Lockout:    guard,
+			Cfg:        cfg,
+			Log:        log,
+			ByEmail:    cooldown.New(limits, "password_reset|email", cfg.EmailCooldown.PerEmail),
+			ByIP:       cooldown.New(limits, "password_reset|ip", cfg.EmailCooldown.PerIP),
+		},
+		PasswordChange: changepassword.ChangePassword{
+			Storage:    storage,
+			TxProvider: storage,
+			Hasher:     hasher,
+			Policy:     policy,
+			Lockout:    guard,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
+		},
+		EmailChange: emailchange.EmailChange{
+			Storage:    storage,
+			TxProvider: storage,
+			Mailer:     mail,
+			Identity:   rules,
+			Keys:       keys,
+			Revoked:    revoked,
+			Cfg:        cfg,
+			Log:        log,
+		},
+		Unlock: unlock.Unlock{
 			Storage: storage,
-			Cfg:     cfg,
+			Lockout: guard,
+			Keys:    keys,
+			Revoked: revoked,
 			Log:     log,
 		},
 	}
@@ -81,13 +212,93 @@ func (s *Service) Current(ctx context.Context, AssetToken string) (*current.User
 }
 
 func (s *Service) LogOutUser(ctx context.Context, AssetToken string) error {
-	return s.LogOut.LogOutUser(ctx, AssetToken)
+	err := s.LogOut.LogOutUser(ctx, AssetToken)
+	if err == nil {
+		s.Introspector.Cache.Clear()
+	}
+	return err
 }
 
-func (s *Service) Refresh(ctx context.Context, RefreshToken string) (string, error) {
-	return s.RefreshUser.Refresh(ctx, RefreshToken)
+func (s *Service) Refresh(ctx context.Context, RefreshToken string) (string, string, error) {
+	accessToken, refreshToken, err := s.RefreshUser.Refresh(ctx, RefreshToken)
+	if errors.Is(err, refresh.ErrRefreshTokenReused) {
+		s.Introspector.Cache.Clear()
+	}
+	return accessToken, refreshToken, err
 }
 
 func (s *Service) CreateUser(ctx context.Context, login, email, password string) error {
 	return s.Registration.CreateUser(ctx, login, email, password)
 }
+
+func (s *Service) PublicKeys(ctx context.Context) myjwt.JWKS {
+	return s.SigningKeys.PublicKeys(ctx)
+}
+
+func (s *Service) RotateSigningKeys(ctx context.Context, AssetToken string) error {
+	return s.SigningKeys.RotateSigningKeys(ctx, AssetToken)
+}
+
+func (s *Service) ListSessions(ctx context.Context, AssetToken string) ([]sessions.Session, error) {
+	return s.Sessions.ListSessions(ctx, AssetToken)
+}
+
+func (s *Service) RevokeSession(ctx context.Context, AssetToken, sessionID string) error {
+	err := s.Sessions.RevokeSession(ctx, AssetToken, sessionID)
+	if err == nil {
+		s.Introspector.Cache.Clear()
+	}
+	return err
+}
+
+func (s *Service) RevokeOtherSessions(ctx context.Context, AssetToken string) (string, string, error) {
+	accessToken, refreshToken, err := s.Sessions.RevokeOtherSessions(ctx, AssetToken)
+	if err == nil {
+		s.Introspector.Cache.Clear()
+	}
+	return accessToken, refreshToken, err
+}
+
+func (s *Service) Introspect(ctx context.Context, clientID, clientSecret, token string) (*introspect.Result, error) {
+	return s.Introspector.Introspect(ctx, clientID, clientSecret, token)
+}
+
+func (s *Service) ResendVerification(ctx context.Context, email string) error {
+	return s.Registration.ResendVerification(ctx, email)
+}
+
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	return s.Reset.RequestPasswordReset(ctx, email)
+}
+
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	err := s.Reset.ResetPassword(ctx, token, newPassword)
+	if err == nil {
+		s.Introspector.Cache.Clear()
+	}
+	return err
+}
+
+func (s *Service) ChangePassword(ctx context.Context, AssetToken, oldPassword, newPassword string, keepCurrentSession bool) (string, string, error) {
+	accessToken, refreshToken, err := s.PasswordChange.ChangePassword(ctx, AssetToken, oldPassword, newPassword, keepCurrentSession)
+	if err == nil {
+		s.Introspector.Cache.Clear()
+	}
+	return accessToken, refreshToken, err
+}
+
+func (s *Service) RequestEmailChange(ctx context.Context, AssetToken, newEmail string) error {
+	return s.EmailChange.RequestEmailChange(ctx, AssetToken, newEmail)
+}
+
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	return s.EmailChange.ConfirmEmailChange(ctx, token)
+}
+
+func (s *Service) CancelEmailChange(ctx context.Context, token string) error {
+	return s.EmailChange.CancelEmailChange(ctx, token)
+}
+
+func (s *Service) UnlockAccount(ctx context.Context, AssetToken, login, ip string) error {
+	return s.Unlock.UnlockAccount(ctx, AssetToken, login, ip)
+}
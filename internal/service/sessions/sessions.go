@@ -0,0 +1,236 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	"github.com/Weit145/Auth_golang/internal/lib/clientinfo"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// Session is what a user sees about one of their signed-in devices.
+type Session struct {
+	Id         string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	Current    bool
+}
+
+type Sessions struct {
+	Storage    SessionsRepo
+	TxProvider storage.TxProvider
+	Log        *slog.Logger
+	Keys       *myjwt.Keys
+	Revoked    revocation.Store
+	Cfg        *config.Config
+}
+
+type SessionsRepo interface {
+	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	ListSessions(ctx context.Context, userId int64) ([]domain.Session, error)
+	RotateSession(ctx context.Context, session *domain.Session, oldHash string) (bool, error)
+	DeleteSession(ctx context.Context, userId int64, id string) error
+	DeleteOtherSessions(ctx context.Context, userId int64, keepId string) error
+	IncrementTokenGeneration(ctx context.Context, user *domain.User) error
+}
+
+func (s *Sessions) ListSessions(ctx context.Context, AssetToken string) ([]Session, error) {
+	const op = "service.ListSessions"
+	log := logger.FromContext(ctx, s.Log)
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get claims from token", logger.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var resp []Session
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.user(ctx, claims)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		sessions, err := s.Storage.ListSessions(ctx, user.Id)
+		if err != nil {
+			return fmt.Errorf("%s: failed to list sessions within transaction: %w", op, err)
+		}
+
+		resp = make([]Session, 0, len(sessions))
+		for _, session := range sessions {
+			resp = append(resp, Session{
+				Id:         session.Id,
+				UserAgent:  session.UserAgent,
+				IP:         session.IP,
+				CreatedAt:  session.CreatedAt,
+				LastUsedAt: session.LastUsedAt,
+				Current:    session.Id == claims.SessionID,
+			})
+		}
+		log.Info("ListSessions method called", slog.String("login", user.Login))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// RevokeSession signs one of the caller's devices out.
+// Sessions of other users look the same as missing ones.
+func (s *Sessions) RevokeSession(ctx context.Context, AssetToken, sessionID string) error {
+	const op = "service.RevokeSession"
+	log := logger.FromContext(ctx, s.Log)
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get claims from token", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.user(ctx, claims)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		session, err := s.Storage.GetSession(ctx, sessionID)
+		if errors.Is(err, domain.ErrSessionNotFound) || err == nil && session.UserId != user.Id {
+			return fmt.Errorf("%s: %w", op, domain.ErrSessionNotFound)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: failed to get session within transaction: %w", op, err)
+		}
+
+		if err = s.Storage.DeleteSession(ctx, user.Id, session.Id); err != nil {
+			return fmt.Errorf("%s: failed to delete session within transaction: %w", op, err)
+		}
+		log.Info("RevokeSession method called", slog.String("login", user.Login), slog.String("session_id", session.Id))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions logs out everywhere except the calling device.
+// Bumping the token generation also kills access tokens already handed out
+// to other devices, so the current session gets a fresh token pair.
+func (s *Sessions) RevokeOtherSessions(ctx context.Context, AssetToken string) (accessToken, refreshToken string, err error) {
+	const op = "service.RevokeOtherSessions"
+	log := logger.FromContext(ctx, s.Log)
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get claims from token", logger.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.TxProvider.WithTx(ctx, func(tx pgx.Tx) error {
+		ctx := storage.ContextWithTx(ctx, tx)
+
+		user, err := s.user(ctx, claims)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		session, err := s.Storage.GetSession(ctx, claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get session within transaction: %w", op, err)
+		}
+
+		if err = s.Storage.DeleteOtherSessions(ctx, user.Id, session.Id); err != nil {
+			return fmt.Errorf("%s: failed to delete sessions within transaction: %w", op, err)
+		}
+		if err = s.Storage.IncrementTokenGeneration(ctx, user); err != nil {
+			return fmt.Errorf("%s: failed to bump token generation within transaction: %w", op, err)
+		}
+
+		tokenClaims := myjwt.Claims{Login: user.Login, SessionID: session.Id, Generation: user.TokenGeneration}
+		refreshToken, err = myjwt.CreateRefreshToken(s.Cfg, s.Keys, log, tokenClaims)
+		if err != nil {
+			return fmt.Errorf("%s: failed to create refresh JWT: %w", op, err)
+		}
+
+		accessToken, err = myjwt.CreateAccessToken(s.Cfg, s.Keys, log, tokenClaims)
+		if err != nil {
+			return fmt.Errorf("%s: failed to create access JWT: %w", op, err)
+		}
+
+		oldHash := session.RefreshTokenHash
+		h := sha256.New()
+		h.Write([]byte(refreshToken))
+		session.RefreshTokenHash = hex.EncodeToString(h.Sum(nil))
+
+		client := clientinfo.FromContext(ctx)
+		session.UserAgent = client.UserAgent
+		session.IP = client.IP
+
+		rotated, err := s.Storage.RotateSession(ctx, session, oldHash)
+		if err != nil {
+			return fmt.Errorf("%s: failed to rotate refresh token within transaction: %w", op, err)
+		}
+		if !rotated {
+			return fmt.Errorf("%s: %w", op, myjwt.ErrTokenRevoked)
+		}
+
+		log.Info("RevokeOtherSessions method called", slog.String("login", user.Login), slog.String("session_id", session.Id))
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// user returns the owner of claims if the session they were issued for is
+// still alive. Managing sessions is closed to restricted tokens, so tokens
+// issued here never carry a scope.
+func (s *Sessions) user(ctx context.Context, claims *myjwt.Claims) (*domain.User, error) {
+	user, err := s.Storage.GetUserByLogin(ctx, claims.Login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by login within transaction: %w", err)
+	}
+	if err = claims.CheckGeneration(user.TokenGeneration); err != nil {
+		return nil, err
+	}
+	if err = account.CheckFull(s.Cfg.Account, user); err != nil {
+		return nil, err
+	}
+	if err = revocation.CheckSession(ctx, s.Storage, claims, user.Id); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,193 @@
+package sessions_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/account"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/logger/slogdiscard"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+	"github.com/Weit145/Auth_golang/internal/service/sessions"
+)
+
+type fakeTx struct{}
+
+func (fakeTx) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return fn(nil)
+}
+
+type fakeSessionsRepo struct {
+	user     domain.User
+	sessions map[string]domain.Session
+}
+
+func (r *fakeSessionsRepo) GetUserByLogin(_ context.Context, login string) (*domain.User, error) {
+	if login != r.user.Login {
+		return nil, domain.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *fakeSessionsRepo) GetSession(_ context.Context, id string) (*domain.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *fakeSessionsRepo) ListSessions(_ context.Context, userId int64) ([]domain.Session, error) {
+	var list []domain.Session
+	for _, session := range r.sessions {
+		if session.UserId == userId {
+			list = append(list, session)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (r *fakeSessionsRepo) RotateSession(_ context.Context, session *domain.Session, oldHash string) (bool, error) {
+	stored, ok := r.sessions[session.Id]
+	if !ok || stored.RefreshTokenHash != oldHash {
+		return false, nil
+	}
+	r.sessions[session.Id] = *session
+	return true, nil
+}
+
+func (r *fakeSessionsRepo) DeleteSession(_ context.Context, userId int64, id string) error {
+	if r.sessions[id].UserId == userId {
+		delete(r.sessions, id)
+	}
+	return nil
+}
+
+func (r *fakeSessionsRepo) DeleteOtherSessions(_ context.Context, userId int64, keepId string) error {
+	for id, session := range r.sessions {
+		if session.UserId == userId && id != keepId {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessionsRepo) IncrementTokenGeneration(_ context.Context, user *domain.User) error {
+	r.user.TokenGeneration++
+	user.TokenGeneration = r.user.TokenGeneration
+	return nil
+}
+
+func newSessions(t *testing.T) (*sessions.Sessions, *fakeSessionsRepo) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWT:      config.JWT{Algorithm: "HS256", Secret: "test-secret"},
+		TokenTTL: config.TokenTTL{Access: time.Hour, Refresh: time.Hour},
+		Account:  config.Account{Unverified: account.UnverifiedDeny},
+	}
+	keys, err := myjwt.LoadKeys(cfg)
+	require.NoError(t, err)
+
+	repo := &fakeSessionsRepo{
+		user: domain.User{Id: 1, Login: "test_user", IsActive: true, IsVerified: true},
+		sessions: map[string]domain.Session{
+			"laptop": {Id: "laptop", UserId: 1, UserAgent: "laptop-agent"},
+			"phone":  {Id: "phone", UserId: 1, UserAgent: "phone-agent"},
+			"other":  {Id: "other", UserId: 2, UserAgent: "other-agent"},
+		},
+	}
+
+	return &sessions.Sessions{
+		Storage:    repo,
+		TxProvider: fakeTx{},
+		Log:        slogdiscard.NewDiscardLogger(),
+		Keys:       keys,
+		Revoked:    revocation.NewMemory(0, slogdiscard.NewDiscardLogger()),
+		Cfg:        cfg,
+	}, repo
+}
+
+func accessToken(t *testing.T, s *sessions.Sessions, sessionID string) string {
+	t.Helper()
+
+	token, err := myjwt.CreateAccessToken(s.Cfg, s.Keys, slogdiscard.NewDiscardLogger(), myjwt.Claims{Login: "test_user", SessionID: sessionID})
+	require.NoError(t, err)
+	return token
+}
+
+func TestListSessions(t *testing.T) {
+	s, _ := newSessions(t)
+
+	list, err := s.ListSessions(context.Background(), accessToken(t, s, "laptop"))
+	require.NoError(t, err)
+	require.Len(t, list, 2, "sessions of other users are not listed")
+	require.Equal(t, "laptop", list[0].Id)
+	require.True(t, list[0].Current)
+	require.Equal(t, "phone", list[1].Id)
+	require.False(t, list[1].Current)
+}
+
+func TestRevokeSession_Own(t *testing.T) {
+	s, repo := newSessions(t)
+	ctx := context.Background()
+	phoneToken := accessToken(t, s, "phone")
+
+	err := s.RevokeSession(ctx, accessToken(t, s, "laptop"), "phone")
+	require.NoError(t, err)
+	require.NotContains(t, repo.sessions, "phone")
+
+	_, err = s.ListSessions(ctx, phoneToken)
+	require.ErrorIs(t, err, myjwt.ErrTokenRevoked, "access tokens of a revoked session stop working")
+}
+
+func TestRevokeSession_Foreign(t *testing.T) {
+	s, repo := newSessions(t)
+
+	err := s.RevokeSession(context.Background(), accessToken(t, s, "laptop"), "other")
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+	require.Contains(t, repo.sessions, "other")
+
+	err = s.RevokeSession(context.Background(), accessToken(t, s, "laptop"), "missing")
+	require.ErrorIs(t, err, domain.ErrSessionNotFound)
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	s, repo := newSessions(t)
+	ctx := context.Background()
+	oldToken := accessToken(t, s, "laptop")
+
+	access, refresh, err := s.RevokeOtherSessions(ctx, oldToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, access)
+	require.NotEmpty(t, refresh)
+	require.Contains(t, repo.sessions, "laptop")
+	require.NotContains(t, repo.sessions, "phone")
+	require.Contains(t, repo.sessions, "other", "sessions of other users are kept")
+
+	_, err = s.ListSessions(ctx, oldToken)
+	require.ErrorIs(t, err, myjwt.ErrTokenRevoked, "the token generation was bumped")
+
+	list, err := s.ListSessions(ctx, access)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.True(t, list[0].Current)
+}
+
+func TestSessions_RestrictedUnverified(t *testing.T) {
+	s, repo := newSessions(t)
+	s.Cfg.Account.Unverified = account.UnverifiedRestricted
+	repo.user.IsVerified = false
+
+	_, err := s.ListSessions(context.Background(), accessToken(t, s, "laptop"))
+	require.ErrorIs(t, err, domain.ErrNotVerified)
+}
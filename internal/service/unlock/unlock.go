@@ -0,0 +1,76 @@
+package unlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	myjwt "github.com/Weit145/Auth_golang/internal/lib/jwt"
+	"github.com/Weit145/Auth_golang/internal/lib/lockout"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/Weit145/Auth_golang/internal/lib/revocation"
+)
+
+const adminRole = "admin"
+
+var ErrForbidden = errors.New("admin role required")
+
+type Unlock struct {
+	Storage UnlockRepo
+	Lockout *lockout.Guard
+	Keys    *myjwt.Keys
+	Revoked revocation.Store
+	Log     *slog.Logger
+}
+
+type UnlockRepo interface {
+	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+}
+
+// UnlockAccount lets an admin lift a brute-force lock on login, on the
+// client address ip, or on both; an empty argument is skipped.
+func (s *Unlock) UnlockAccount(ctx context.Context, AssetToken, login, ip string) error {
+	const op = "service.UnlockAccount"
+	log := logger.FromContext(ctx, s.Log)
+
+	if login != "" {
+		login = identity.NormalizeLogin(login)
+	}
+
+	claims, err := myjwt.GetClaims(AssetToken, s.Keys, myjwt.TokenAccess)
+	if err != nil {
+		log.Error("failed to get claims from token", logger.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.Check(ctx, s.Revoked, claims); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	admin, err := s.Storage.GetUserByLogin(ctx, claims.Login)
+	if err != nil {
+		return fmt.Errorf("%s: failed to get user by login: %w", op, err)
+	}
+	if err = claims.CheckGeneration(admin.TokenGeneration); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = admin.CheckActive(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = revocation.CheckSession(ctx, s.Storage, claims, admin.Id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if admin.Role != adminRole {
+		return fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if err = s.Lockout.Unlock(ctx, login, ip); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("UnlockAccount method called", slog.String("admin", admin.Login), slog.String("login", login), slog.String("ip", ip))
+	return nil
+}
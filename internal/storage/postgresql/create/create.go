@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/Weit145/Auth_golang/internal/domain"
 	"github.com/Weit145/Auth_golang/internal/storage"
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -19,11 +20,11 @@ func CreateUserOp(ctx context.Context, runner storage.QueryRunner, login, email,
 	_, err := runner.Exec(ctx, stmt, login, email, passwordHash)
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == UniqueViolation {
-			if pgErr.ConstraintName == "auth_login_key" {
-				return fmt.Errorf("%s: login already exists", op)
+			if pgErr.ConstraintName == "auth_login_key" || pgErr.ConstraintName == "auth_login_lower_key" {
+				return fmt.Errorf("%s: %w", op, domain.ErrUserExists)
 			}
-			if pgErr.ConstraintName == "auth_email_key" {
-				return fmt.Errorf("%s: email already exists", op)
+			if pgErr.ConstraintName == "auth_email_key" || pgErr.ConstraintName == "auth_email_lower_key" {
+				return fmt.Errorf("%s: %w", op, domain.ErrEmailTaken)
 			}
 		}
 		return fmt.Errorf("%s: failed to insert user: %w", op, err)
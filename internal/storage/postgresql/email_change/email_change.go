@@ -0,0 +1,70 @@
+package emailchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateEmailChangeOp stores newEmail as the user's pending address,
+// replacing any change that is still waiting for confirmation.
+func CreateEmailChangeOp(ctx context.Context, runner storage.QueryRunner, userId int64, newEmail string, expiresAt time.Time) error {
+	const op = "storage.postgresql.emailchange.CreateEmailChangeOp"
+
+	stmt := `INSERT INTO email_changes (user_id, new_email, expires_at) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO UPDATE
+	SET new_email = EXCLUDED.new_email, confirm_hash = NULL, cancel_hash = NULL,
+		expires_at = EXCLUDED.expires_at, created_at = now()`
+	_, err := runner.Exec(ctx, stmt, userId, newEmail, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetEmailChangeTokensOp attaches token hashes to a pending change.
+// ok is false if the change was confirmed, cancelled, replaced or has expired.
+func SetEmailChangeTokensOp(ctx context.Context, runner storage.QueryRunner, userId int64, newEmail, confirmHash, cancelHash string) (ok bool, err error) {
+	const op = "storage.postgresql.emailchange.SetEmailChangeTokensOp"
+
+	stmt := `UPDATE email_changes SET confirm_hash = $3, cancel_hash = $4
+	WHERE user_id = $1 AND new_email = $2 AND expires_at > now()`
+	tag, err := runner.Exec(ctx, stmt, userId, newEmail, confirmHash, cancelHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ConsumeEmailChangeOp removes an unexpired pending change by its
+// confirmation token hash. ok is false if there was no such change.
+func ConsumeEmailChangeOp(ctx context.Context, runner storage.QueryRunner, confirmHash string) (userId int64, newEmail string, ok bool, err error) {
+	const op = "storage.postgresql.emailchange.ConsumeEmailChangeOp"
+
+	stmt := `DELETE FROM email_changes
+	WHERE confirm_hash = $1 AND expires_at > now()
+	RETURNING user_id, new_email`
+	err = runner.QueryRow(ctx, stmt, confirmHash).Scan(&userId, &newEmail)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, "", false, nil
+		}
+		return 0, "", false, fmt.Errorf("%s: %w", op, err)
+	}
+	return userId, newEmail, true, nil
+}
+
+// CancelEmailChangeOp removes a pending change by its cancel token hash.
+func CancelEmailChangeOp(ctx context.Context, runner storage.QueryRunner, cancelHash string) (ok bool, err error) {
+	const op = "storage.postgresql.emailchange.CancelEmailChangeOp"
+
+	stmt := `DELETE FROM email_changes WHERE cancel_hash = $1`
+	tag, err := runner.Exec(ctx, stmt, cancelHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,60 @@
+package loginattempt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/storage"
+)
+
+// LoginAttemptsForUpdateOp returns the counter of key, creating an empty
+// one if needed, and locks its row until the transaction ends.
+func LoginAttemptsForUpdateOp(ctx context.Context, runner storage.QueryRunner, key string) (*domain.LoginAttempts, error) {
+	const op = "storage.postgresql.loginattempt.LoginAttemptsForUpdateOp"
+
+	stmt := `INSERT INTO login_attempts (key) VALUES ($1) ON CONFLICT (key) DO NOTHING`
+	if _, err := runner.Exec(ctx, stmt, key); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt = `SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1 FOR UPDATE`
+	var attempts domain.LoginAttempts
+	var lockedUntil *time.Time
+	err := runner.QueryRow(ctx, stmt, key).Scan(&attempts.Key, &attempts.Failures, &attempts.LastFailureAt, &lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if lockedUntil != nil {
+		attempts.LockedUntil = *lockedUntil
+	}
+	return &attempts, nil
+}
+
+func SaveLoginAttemptsOp(ctx context.Context, runner storage.QueryRunner, attempts *domain.LoginAttempts) error {
+	const op = "storage.postgresql.loginattempt.SaveLoginAttemptsOp"
+
+	var lockedUntil *time.Time
+	if !attempts.LockedUntil.IsZero() {
+		lockedUntil = &attempts.LockedUntil
+	}
+	stmt := `UPDATE login_attempts SET failures = $2, last_failure_at = $3, locked_until = $4 WHERE key = $1`
+	_, err := runner.Exec(ctx, stmt, attempts.Key, attempts.Failures, attempts.LastFailureAt, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ClearLoginFailuresOp forgets failures and locks for keys.
+func ClearLoginFailuresOp(ctx context.Context, runner storage.QueryRunner, keys []string) error {
+	const op = "storage.postgresql.loginattempt.ClearLoginFailuresOp"
+
+	stmt := `DELETE FROM login_attempts WHERE key = ANY($1)`
+	_, err := runner.Exec(ctx, stmt, keys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+	"github.com/Weit145/Auth_golang/internal/storage"
+)
+
+// normalizeMigration names the identity normalization in schema_migrations.
+const normalizeMigration = "normalize_identities"
+
+// identityFields are the auth columns that are stored normalized, with the
+// function that normalizes them.
+var identityFields = []struct {
+	name      string
+	normalize func(string) string
+}{
+	{name: "login", normalize: identity.NormalizeLogin},
+	{name: "email", normalize: identity.NormalizeEmail},
+}
+
+// normalizeIdentities brings accounts created before logins and emails
+// were normalized in line with the form lookups now use: lowercase NFKC,
+// and punycode domains for emails. It runs in one transaction, so a crash
+// leaves the table as it was. Values that convert without a clash are
+// rewritten; accounts that would end up with the same value are reported
+// and left alone, since merging them needs a person. Once a column has no
+// clashes left, a unique index on lower(column) keeps it that way, and once
+// both columns are clean the migration is recorded and never runs again.
+func normalizeIdentities(ctx context.Context, log *slog.Logger, tx storage.QueryRunner) error {
+	const op = "storage.postgresql.normalizeIdentities"
+
+	var done bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE name = $1)`, normalizeMigration).Scan(&done)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if done {
+		return nil
+	}
+
+	// Keep accounts from being created or renamed while they are scanned.
+	if _, err = tx.Exec(ctx, `LOCK TABLE auth IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("%s: lock auth: %w", op, err)
+	}
+
+	clean := true
+	for _, field := range identityFields {
+		values, err := fieldValues(ctx, tx, field.name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		rewrites, clashes := planNormalization(values, field.normalize)
+		reportClashes(log, field.name, values, clashes)
+
+		for _, id := range sortedIds(rewrites) {
+			stmt := fmt.Sprintf(`UPDATE auth SET %s = $1 WHERE id = $2`, field.name)
+			if _, err = tx.Exec(ctx, stmt, rewrites[id], id); err != nil {
+				return fmt.Errorf("%s: normalize %s of user %d: %w", op, field.name, id, err)
+			}
+		}
+		if len(rewrites) > 0 {
+			log.Info("normalized existing accounts", slog.String("field", field.name), slog.Int("count", len(rewrites)))
+		}
+
+		if len(clashes) > 0 {
+			clean = false
+			continue
+		}
+		stmt := fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS auth_%[1]s_lower_key ON auth (lower(%[1]s))`, field.name)
+		if _, err = tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: index %s: %w", op, field.name, err)
+		}
+	}
+
+	if !clean {
+		return nil
+	}
+	if _, err = tx.Exec(ctx, `INSERT INTO schema_migrations (name) VALUES ($1)`, normalizeMigration); err != nil {
+		return fmt.Errorf("%s: record migration: %w", op, err)
+	}
+	return nil
+}
+
+// planNormalization returns the new value of every account whose value
+// changes once normalized, and the groups of accounts, sorted by id, that
+// would share a normalized value. Clashing accounts are never rewritten.
+func planNormalization(values map[int64]string, normalize func(string) string) (map[int64]string, [][]int64) {
+	byNormalized := make(map[string][]int64)
+	for id, value := range values {
+		normalized := normalize(value)
+		byNormalized[normalized] = append(byNormalized[normalized], id)
+	}
+
+	rewrites := make(map[int64]string)
+	var clashes [][]int64
+	for normalized, ids := range byNormalized {
+		if len(ids) > 1 {
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			clashes = append(clashes, ids)
+			continue
+		}
+		if values[ids[0]] != normalized {
+			rewrites[ids[0]] = normalized
+		}
+	}
+	sort.Slice(clashes, func(i, j int) bool { return clashes[i][0] < clashes[j][0] })
+	return rewrites, clashes
+}
+
+// reportClashes logs every clashing account on its own: lookups use the
+// normalized value, so all but an account already stored in that form can
+// no longer sign in until an operator merges or renames them.
+func reportClashes(log *slog.Logger, field string, values map[int64]string, clashes [][]int64) {
+	for _, ids := range clashes {
+		for _, id := range ids {
+			log.Error("account clashes with others once normalized and must be merged by hand",
+				slog.String("field", field), slog.Int64("user_id", id), slog.String("value", values[id]), slog.Any("clashes_with", ids))
+		}
+	}
+}
+
+// fieldValues maps the id of every account to its value of field.
+func fieldValues(ctx context.Context, tx storage.QueryRunner, field string) (map[int64]string, error) {
+	stmt := fmt.Sprintf(`SELECT id, %s FROM auth`, field)
+	rows, err := tx.Query(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var value string
+		if err = rows.Scan(&id, &value); err != nil {
+			return nil, err
+		}
+		values[id] = value
+	}
+	return values, rows.Err()
+}
+
+func sortedIds(m map[int64]string) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,50 @@
+package postgresql
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Weit145/Auth_golang/internal/lib/identity"
+)
+
+func TestPlanNormalization(t *testing.T) {
+	values := map[int64]string{
+		1: "alice",
+		2: "Bob",
+		3: "CAROL",
+		4: "carol",
+		5: "Carol",
+	}
+
+	rewrites, clashes := planNormalization(values, identity.NormalizeLogin)
+
+	require.Equal(t, map[int64]string{2: "bob"}, rewrites, "already normalized and clashing accounts are left alone")
+	require.Equal(t, [][]int64{{3, 4, 5}}, clashes)
+}
+
+func TestReportClashes(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil))
+	values := map[int64]string{3: "CAROL", 4: "carol"}
+
+	reportClashes(log, "login", values, [][]int64{{3, 4}})
+
+	var ids []int64
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record struct {
+			Level  string `json:"level"`
+			Field  string `json:"field"`
+			UserId int64  `json:"user_id"`
+		}
+		require.NoError(t, dec.Decode(&record))
+		require.Equal(t, "ERROR", record.Level)
+		require.Equal(t, "login", record.Field)
+		ids = append(ids, record.UserId)
+	}
+	require.Equal(t, []int64{3, 4}, ids, "every clashing account is reported")
+}
@@ -0,0 +1,85 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/storage"
+)
+
+func EnqueueOutboxOp(ctx context.Context, runner storage.QueryRunner, kind string, payload []byte) error {
+	const op = "storage.postgresql.outbox.EnqueueOutboxOp"
+
+	stmt := `INSERT INTO outbox (kind, payload) VALUES ($1, $2)`
+	_, err := runner.Exec(ctx, stmt, kind, payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ClaimOutboxOp leases up to limit due messages. A claimed message is hidden
+// from other dispatchers until the lease runs out, so a crash mid-delivery
+// only delays it.
+func ClaimOutboxOp(ctx context.Context, runner storage.QueryRunner, limit int, lease time.Duration) ([]domain.OutboxMessage, error) {
+	const op = "storage.postgresql.outbox.ClaimOutboxOp"
+
+	stmt := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM outbox WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, kind, payload, status, attempts, next_attempt_at, last_error, created_at`
+	rows, err := runner.Query(ctx, stmt, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var messages []domain.OutboxMessage
+	for rows.Next() {
+		var msg domain.OutboxMessage
+		if err = rows.Scan(
+			&msg.Id,
+			&msg.Kind,
+			&msg.Payload,
+			&msg.Status,
+			&msg.Attempts,
+			&msg.NextAttemptAt,
+			&msg.LastError,
+			&msg.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return messages, nil
+}
+
+func CompleteOutboxOp(ctx context.Context, runner storage.QueryRunner, id int64) error {
+	const op = "storage.postgresql.outbox.CompleteOutboxOp"
+
+	stmt := `DELETE FROM outbox WHERE id = $1`
+	_, err := runner.Exec(ctx, stmt, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func FailOutboxOp(ctx context.Context, runner storage.QueryRunner, msg *domain.OutboxMessage) error {
+	const op = "storage.postgresql.outbox.FailOutboxOp"
+
+	stmt := `UPDATE outbox SET status = $2, next_attempt_at = $3, last_error = $4 WHERE id = $1`
+	_, err := runner.Exec(ctx, stmt, msg.Id, msg.Status, msg.NextAttemptAt, msg.LastError)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package passwordreset
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+func CreatePasswordResetOp(ctx context.Context, runner storage.QueryRunner, userId int64, tokenHash string, expiresAt time.Time) error {
+	const op = "storage.postgresql.passwordreset.CreatePasswordResetOp"
+
+	stmt := `INSERT INTO password_resets (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`
+	_, err := runner.Exec(ctx, stmt, tokenHash, userId, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ConsumePasswordResetOp marks an unused, unexpired token as used and
+// returns its user. ok is false if there was no such token.
+func ConsumePasswordResetOp(ctx context.Context, runner storage.QueryRunner, tokenHash string) (userId int64, ok bool, err error) {
+	const op = "storage.postgresql.passwordreset.ConsumePasswordResetOp"
+
+	stmt := `UPDATE password_resets SET used_at = now()
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+	RETURNING user_id`
+	err = runner.QueryRow(ctx, stmt, tokenHash).Scan(&userId)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("%s: %w", op, err)
+	}
+	return userId, true, nil
+}
+
+func DeletePasswordResetsOp(ctx context.Context, runner storage.QueryRunner, userId int64) error {
+	const op = "storage.postgresql.passwordreset.DeletePasswordResetsOp"
+
+	stmt := `DELETE FROM password_resets WHERE user_id = $1`
+	_, err := runner.Exec(ctx, stmt, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/config"
+	"github.com/Weit145/Auth_golang/internal/lib/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// poolConfig builds the pool settings from cfg: the DSN with its sslmode
+// replaced by TLSMode, the password from PasswordFile, the pool sizes and
+// lifetimes, and statement_timeout for every connection.
+func poolConfig(cfg config.Storage) (*pgxpool.Config, error) {
+	dsn := cfg.DSN
+	if cfg.TLSMode != "" {
+		var err error
+		if dsn, err = withTLSMode(dsn, cfg.TLSMode); err != nil {
+			return nil, err
+		}
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		// The error of ParseConfig quotes the DSN, password included.
+		return nil, errors.New("invalid storage dsn")
+	}
+
+	if cfg.PasswordFile != "" {
+		password, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("read password file: %w", err)
+		}
+		poolCfg.ConnConfig.Password = strings.TrimRight(string(password), "\r\n")
+	}
+
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = cfg.MaxConns
+	}
+	poolCfg.MinConns = cfg.MinConns
+	poolCfg.MaxConnLifetime = cfg.MaxConnLifetime
+	poolCfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+
+	if cfg.StatementTimeout > 0 {
+		poolCfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	return poolCfg, nil
+}
+
+// withTLSMode sets sslmode in a URL or keyword/value DSN.
+func withTLSMode(dsn, mode string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", errors.New("invalid storage dsn")
+		}
+		q := u.Query()
+		q.Set("sslmode", mode)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+	// A later keyword overrides an earlier one.
+	return strings.TrimSpace(dsn + " sslmode=" + mode), nil
+}
+
+// connect opens the pool and waits until the database answers, retrying
+// with a backoff doubling from cfg.BaseBackoff to cfg.MaxBackoff until
+// ctx is done.
+func connect(ctx context.Context, log *slog.Logger, poolCfg *pgxpool.Config, cfg config.Storage) (*pgxpool.Pool, error) {
+	backoff := cfg.BaseBackoff
+	for attempt := 1; ; attempt++ {
+		pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+		if err == nil {
+			if err = pool.Ping(ctx); err == nil {
+				return pool, nil
+			}
+			pool.Close()
+		}
+
+		log.Warn("Failed to connect to storage, retrying...",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			logger.Err(err),
+		)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
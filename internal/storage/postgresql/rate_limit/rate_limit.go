@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// TakeTokenOp spends one request from the bucket of key and returns zero,
+// or leaves the bucket alone and returns how long until the request fits.
+// A bucket is the time it is full again; the upsert only moves it forward
+// while it stays within burst requests of now.
+func TakeTokenOp(ctx context.Context, runner storage.QueryRunner, key string, every time.Duration, burst int) (time.Duration, error) {
+	const op = "storage.postgresql.ratelimit.TakeTokenOp"
+
+	window := time.Duration(burst) * every
+	stmt := `INSERT INTO rate_limits AS r (key, full_at) VALUES ($1, now() + make_interval(secs => $2))
+	ON CONFLICT (key) DO UPDATE SET full_at = GREATEST(r.full_at, now()) + make_interval(secs => $2)
+		WHERE GREATEST(r.full_at, now()) + make_interval(secs => $2) <= now() + make_interval(secs => $3)
+	RETURNING full_at`
+	var fullAt time.Time
+	err := runner.QueryRow(ctx, stmt, key, every.Seconds(), window.Seconds()).Scan(&fullAt)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt = `SELECT EXTRACT(EPOCH FROM GREATEST(full_at, now()) + make_interval(secs => $2) - now())::float8 - $3
+	FROM rate_limits WHERE key = $1`
+	var wait float64
+	if err = runner.QueryRow(ctx, stmt, key, every.Seconds(), window.Seconds()).Scan(&wait); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	// The bucket may have refilled between the two statements.
+	return max(time.Duration(wait*float64(time.Second)), time.Nanosecond), nil
+}
+
+// PurgeRateLimitsOp drops buckets that are full again.
+func PurgeRateLimitsOp(ctx context.Context, runner storage.QueryRunner) (int64, error) {
+	const op = "storage.postgresql.ratelimit.PurgeRateLimitsOp"
+
+	stmt := `DELETE FROM rate_limits WHERE full_at <= now()`
+	tag, err := runner.Exec(ctx, stmt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,42 @@
+package revokedtoken
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Weit145/Auth_golang/internal/storage"
+)
+
+func RevokeTokenOp(ctx context.Context, runner storage.QueryRunner, jti string, expiresAt time.Time) error {
+	const op = "storage.postgresql.revokedtoken.RevokeTokenOp"
+
+	stmt := `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+	_, err := runner.Exec(ctx, stmt, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func IsTokenRevokedOp(ctx context.Context, runner storage.QueryRunner, jti string) (bool, error) {
+	const op = "storage.postgresql.revokedtoken.IsTokenRevokedOp"
+
+	var revoked bool
+	stmt := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > now())`
+	if err := runner.QueryRow(ctx, stmt, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return revoked, nil
+}
+
+func PurgeRevokedTokensOp(ctx context.Context, runner storage.QueryRunner) (int64, error) {
+	const op = "storage.postgresql.revokedtoken.PurgeRevokedTokensOp"
+
+	stmt := `DELETE FROM revoked_tokens WHERE expires_at <= now()`
+	tag, err := runner.Exec(ctx, stmt)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -12,7 +12,7 @@ import (
 func GetUserByEmailOp(ctx context.Context, runner storage.QueryRunner, email string) (*domain.User, error) {
 	const op = "storage.postgresql.select_user.GetUserByEmailOp"
 
-	stmt := `SELECT id, login, email, password_hash, is_active, is_verified, role, refresh_token_hash FROM auth WHERE email = $1`
+	stmt := `SELECT id, login, email, password_hash, is_active, is_verified, role, token_generation FROM auth WHERE email = $1`
 	var user domain.User
 	err := runner.QueryRow(ctx, stmt, email).Scan(
 		&user.Id,
@@ -22,11 +22,11 @@ func GetUserByEmailOp(ctx context.Context, runner storage.QueryRunner, email str
 		&user.IsActive,
 		&user.IsVerified,
 		&user.Role,
-		&user.RefreshTokenHash,
+		&user.TokenGeneration,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("%s: user not found", op)
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrUserNotFound)
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -37,7 +37,7 @@ func GetUserByEmailOp(ctx context.Context, runner storage.QueryRunner, email str
 func GetUserByLoginOp(ctx context.Context, runner storage.QueryRunner, login string) (*domain.User, error) {
 	const op = "storage.postgresql.select_user.GetUserByLoginOp"
 
-	stmt := `SELECT id, login, email, password_hash, is_active, is_verified, role, refresh_token_hash FROM auth WHERE login = $1`
+	stmt := `SELECT id, login, email, password_hash, is_active, is_verified, role, token_generation FROM auth WHERE login = $1`
 	var user domain.User
 	err := runner.QueryRow(ctx, stmt, login).Scan(
 		&user.Id,
@@ -47,11 +47,36 @@ func GetUserByLoginOp(ctx context.Context, runner storage.QueryRunner, login str
 		&user.IsActive,
 		&user.IsVerified,
 		&user.Role,
-		&user.RefreshTokenHash,
+		&user.TokenGeneration,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("%s: user not found", op)
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &user, nil
+}
+
+func GetUserByIdOp(ctx context.Context, runner storage.QueryRunner, id int64) (*domain.User, error) {
+	const op = "storage.postgresql.select_user.GetUserByIdOp"
+
+	stmt := `SELECT id, login, email, password_hash, is_active, is_verified, role, token_generation FROM auth WHERE id = $1`
+	var user domain.User
+	err := runner.QueryRow(ctx, stmt, id).Scan(
+		&user.Id,
+		&user.Login,
+		&user.Email,
+		&user.PasswordHash,
+		&user.IsActive,
+		&user.IsVerified,
+		&user.Role,
+		&user.TokenGeneration,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrUserNotFound)
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
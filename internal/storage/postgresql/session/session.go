@@ -0,0 +1,131 @@
+package session
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+func CreateSessionOp(ctx context.Context, runner storage.QueryRunner, session *domain.Session) error {
+	const op = "storage.postgresql.session.CreateSessionOp"
+
+	stmt := `INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip) VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at, last_used_at`
+	err := runner.QueryRow(ctx, stmt, session.Id, session.UserId, session.RefreshTokenHash, session.UserAgent, session.IP).Scan(
+		&session.CreatedAt,
+		&session.LastUsedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func GetSessionOp(ctx context.Context, runner storage.QueryRunner, id string) (*domain.Session, error) {
+	const op = "storage.postgresql.session.GetSessionOp"
+
+	stmt := `SELECT id, user_id, refresh_token_hash, created_at, last_used_at, user_agent, ip FROM sessions WHERE id = $1`
+	var session domain.Session
+	err := runner.QueryRow(ctx, stmt, id).Scan(
+		&session.Id,
+		&session.UserId,
+		&session.RefreshTokenHash,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.UserAgent,
+		&session.IP,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%s: %w", op, domain.ErrSessionNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &session, nil
+}
+
+// RotateSessionOp stores a new refresh token hash only if the session still holds oldHash,
+// so two concurrent refreshes with the same token cannot both succeed.
+func RotateSessionOp(ctx context.Context, runner storage.QueryRunner, session *domain.Session, oldHash string) (bool, error) {
+	const op = "storage.postgresql.session.RotateSessionOp"
+
+	stmt := `UPDATE sessions SET refresh_token_hash = $1, last_used_at = now(), user_agent = $2, ip = $3
+	WHERE id = $4 AND refresh_token_hash = $5`
+	tag, err := runner.Exec(ctx, stmt, session.RefreshTokenHash, session.UserAgent, session.IP, session.Id, oldHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func DeleteSessionOp(ctx context.Context, runner storage.QueryRunner, userId int64, id string) error {
+	const op = "storage.postgresql.session.DeleteSessionOp"
+
+	stmt := `DELETE FROM sessions WHERE id = $1 AND user_id = $2`
+	_, err := runner.Exec(ctx, stmt, id, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func ListSessionsOp(ctx context.Context, runner storage.QueryRunner, userId int64) ([]domain.Session, error) {
+	const op = "storage.postgresql.session.ListSessionsOp"
+
+	stmt := `SELECT id, user_id, refresh_token_hash, created_at, last_used_at, user_agent, ip FROM sessions
+	WHERE user_id = $1 ORDER BY last_used_at DESC`
+	rows, err := runner.Query(ctx, stmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []domain.Session
+	for rows.Next() {
+		var session domain.Session
+		if err = rows.Scan(
+			&session.Id,
+			&session.UserId,
+			&session.RefreshTokenHash,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.UserAgent,
+			&session.IP,
+		); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sessions, nil
+}
+
+func DeleteOtherSessionsOp(ctx context.Context, runner storage.QueryRunner, userId int64, keepId string) error {
+	const op = "storage.postgresql.session.DeleteOtherSessionsOp"
+
+	stmt := `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`
+	_, err := runner.Exec(ctx, stmt, userId, keepId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func DeleteUserSessionsOp(ctx context.Context, runner storage.QueryRunner, userId int64) error {
+	const op = "storage.postgresql.session.DeleteUserSessionsOp"
+
+	stmt := `DELETE FROM sessions WHERE user_id = $1`
+	_, err := runner.Exec(ctx, stmt, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package updateemail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/storage"
+	"github.com/Weit145/Auth_golang/internal/storage/postgresql/create"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// UpdateEmailOp sets a new, verified email address. If another account got
+// the address first, auth_email_key rejects it and ErrEmailTaken is returned.
+func UpdateEmailOp(ctx context.Context, runner storage.QueryRunner, user *domain.User) error {
+	const op = "storage.postgresql.updateemail.UpdateEmailOp"
+
+	stmt := `UPDATE auth SET email = $1, is_verified = TRUE WHERE id = $2`
+	_, err := runner.Exec(ctx, stmt, user.Email, user.Id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == create.UniqueViolation &&
+			(pgErr.ConstraintName == "auth_email_key" || pgErr.ConstraintName == "auth_email_lower_key") {
+			return fmt.Errorf("%s: %w", op, domain.ErrEmailTaken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	user.IsVerified = true
+	return nil
+}
@@ -0,0 +1,32 @@
+package updatepassword
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Weit145/Auth_golang/internal/domain"
+	"github.com/Weit145/Auth_golang/internal/storage"
+)
+
+func UpdatePasswordHashOp(ctx context.Context, runner storage.QueryRunner, user *domain.User) error {
+	const op = "storage.postgresql.updatepassword.UpdatePasswordHashOp"
+
+	stmt := `UPDATE auth SET password_hash = $1 WHERE id = $2`
+	_, err := runner.Exec(ctx, stmt, user.PasswordHash, user.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ChangePasswordOp sets a new password hash and bumps the token generation,
+// so tokens issued under the old password stop working.
+func ChangePasswordOp(ctx context.Context, runner storage.QueryRunner, user *domain.User) error {
+	const op = "storage.postgresql.updatepassword.ChangePasswordOp"
+
+	stmt := `UPDATE auth SET password_hash = $1, token_generation = token_generation + 1 WHERE id = $2 RETURNING token_generation`
+	if err := runner.QueryRow(ctx, stmt, user.PasswordHash, user.Id).Scan(&user.TokenGeneration); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -11,8 +11,8 @@ import (
 func UpdateVerifiedOp(ctx context.Context, runner storage.QueryRunner, user *domain.User) error {
 	const op = "storage.postgresql.updateverified.UpdateVerifiedOp"
 
-	stmt := `UPDATE auth SET is_verified = $1, refresh_token_hash = $2 WHERE id = $3`
-	_, err := runner.Exec(ctx, stmt, user.IsVerified, user.RefreshTokenHash, user.Id)
+	stmt := `UPDATE auth SET is_verified = $1 WHERE id = $2`
+	_, err := runner.Exec(ctx, stmt, user.IsVerified, user.Id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/Weit145/Auth_golang/internal/domain"
 	"github.com/jackc/pgx/v5"
@@ -15,14 +16,57 @@ type QueryRunner interface {
 }
 
 type Storage interface {
+	TxProvider
 	RegistrationRepo(ctx context.Context, login, email, passwordHash string) error
 	AuthenticateRepo(ctx context.Context, user *domain.User) error
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
 	GetUserByLogin(ctx context.Context, login string) (*domain.User, error)
+	GetUserById(ctx context.Context, id int64) (*domain.User, error)
 	ConfirmRepo(ctx context.Context, user *domain.User) error
-	UpdateRefreshToken(ctx context.Context, user *domain.User) error
+	CreateSession(ctx context.Context, session *domain.Session) error
+	GetSession(ctx context.Context, id string) (*domain.Session, error)
+	RotateSession(ctx context.Context, session *domain.Session, oldHash string) (bool, error)
+	DeleteSession(ctx context.Context, userId int64, id string) error
+	ListSessions(ctx context.Context, userId int64) ([]domain.Session, error)
+	DeleteOtherSessions(ctx context.Context, userId int64, keepId string) error
+	DeleteUserSessions(ctx context.Context, userId int64) error
+	IncrementTokenGeneration(ctx context.Context, user *domain.User) error
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeRevokedTokens(ctx context.Context) (int64, error)
+	EnqueueOutbox(ctx context.Context, kind string, payload []byte) error
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxMessage, error)
+	CompleteOutbox(ctx context.Context, id int64) error
+	FailOutbox(ctx context.Context, msg *domain.OutboxMessage) error
+	UpdatePasswordHash(ctx context.Context, user *domain.User) error
+	ChangePassword(ctx context.Context, user *domain.User) error
+	CreatePasswordReset(ctx context.Context, userId int64, tokenHash string, expiresAt time.Time) error
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (int64, bool, error)
+	DeletePasswordResets(ctx context.Context, userId int64) error
+	UpdateEmail(ctx context.Context, user *domain.User) error
+	CreateEmailChange(ctx context.Context, userId int64, newEmail string, expiresAt time.Time) error
+	SetEmailChangeTokens(ctx context.Context, userId int64, newEmail, confirmHash, cancelHash string) (bool, error)
+	ConsumeEmailChange(ctx context.Context, confirmHash string) (int64, string, bool, error)
+	CancelEmailChange(ctx context.Context, cancelHash string) (bool, error)
+	LoginAttemptsForUpdate(ctx context.Context, key string) (*domain.LoginAttempts, error)
+	SaveLoginAttempts(ctx context.Context, attempts *domain.LoginAttempts) error
+	ClearLoginFailures(ctx context.Context, keys []string) error
+	TakeToken(ctx context.Context, key string, every time.Duration, burst int) (time.Duration, error)
+	PurgeRateLimits(ctx context.Context) (int64, error)
 }
 
 type TxProvider interface {
 	WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error
 }
+
+type txKey struct{}
+
+// ContextWithTx returns a copy of ctx that makes repository calls run inside tx.
+func ContextWithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}